@@ -0,0 +1,10 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.20;
+
+interface IStorage {
+    event ValueChanged(uint256 indexed oldValue, uint256 indexed newValue);
+
+    function set(uint256 value) external;
+
+    function get() external view returns (uint256);
+}
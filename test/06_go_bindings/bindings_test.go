@@ -3,6 +3,7 @@ package bindings_test
 import (
 	"testing"
 
+	istorage "test/06_go_bindings/istorage"
 	storage "test/06_go_bindings/simplestorage"
 )
 
@@ -47,3 +48,19 @@ func TestEvents(t *testing.T) {
 		t.Error("Expected 'OwnerChanged' event in ABI")
 	}
 }
+
+func TestInterfaceABI(t *testing.T) {
+	abi, err := istorage.InstanceMetaData.GetAbi()
+	if err != nil {
+		t.Fatalf("Failed to parse ABI: %v", err)
+	}
+
+	for _, method := range []string{"set", "get"} {
+		if _, ok := abi.Methods[method]; !ok {
+			t.Errorf("Expected '%s' method in interface ABI", method)
+		}
+	}
+	if _, ok := abi.Events["ValueChanged"]; !ok {
+		t.Error("Expected 'ValueChanged' event in interface ABI")
+	}
+}
@@ -0,0 +1,246 @@
+// Package coverage reads the LCOV reports written by forge coverage and converts
+// them into the Cobertura XML that Please reads from a test's coverage file.
+package coverage
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Report is the line coverage of a set of files.
+type Report struct {
+	// Files are keyed by path.
+	Files map[string]*File
+}
+
+// File is the line coverage of one file.
+type File struct {
+	Path string
+	// Lines maps each executable line to the number of times it ran.
+	Lines map[int]uint64
+}
+
+// New returns an empty report.
+func New() *Report {
+	return &Report{Files: map[string]*File{}}
+}
+
+// file returns the file at path, adding it if needed.
+func (r *Report) file(path string) *File {
+	f, ok := r.Files[path]
+	if !ok {
+		f = &File{Path: path, Lines: map[int]uint64{}}
+		r.Files[path] = f
+	}
+	return f
+}
+
+// ParseLCOV reads an LCOV report. Only line coverage (DA records) is kept.
+func ParseLCOV(r io.Reader) (*Report, error) {
+	report := New()
+	var current *File
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		record, value, _ := strings.Cut(line, ":")
+		switch record {
+		case "SF":
+			current = report.file(value)
+		case "DA":
+			if current == nil {
+				return nil, fmt.Errorf("line %d: DA record outside a file", n)
+			}
+			fields := strings.Split(value, ",")
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: expected \"DA:line,hits\", got %q", n, line)
+			}
+			number, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid line number %q", n, fields[0])
+			}
+			hits, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid hit count %q", n, fields[1])
+			}
+			current.Lines[number] += hits
+		case "end_of_record":
+			current = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read LCOV report: %w", err)
+	}
+	return report, nil
+}
+
+// LoadLCOV reads an LCOV report file.
+func LoadLCOV(filename string) (*Report, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open coverage report: %w", err)
+	}
+	defer f.Close()
+	report, err := ParseLCOV(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return report, nil
+}
+
+// Merge adds the coverage in other to r, summing the hits of lines in both.
+func (r *Report) Merge(other *Report) {
+	for _, f := range other.Files {
+		merged := r.file(f.Path)
+		for line, hits := range f.Lines {
+			merged.Lines[line] += hits
+		}
+	}
+}
+
+// Rewrite returns a report with each file's path replaced by mapPath's result.
+// Files for which mapPath returns false are dropped, and files that map to the
+// same path are merged.
+func (r *Report) Rewrite(mapPath func(path string) (string, bool)) *Report {
+	rewritten := New()
+	for _, f := range r.Files {
+		path, ok := mapPath(f.Path)
+		if !ok {
+			continue
+		}
+		rewritten.Merge(&Report{Files: map[string]*File{path: {Path: path, Lines: f.Lines}}})
+	}
+	return rewritten
+}
+
+// paths returns the report's file paths, sorted.
+func (r *Report) paths() []string {
+	paths := make([]string, 0, len(r.Files))
+	for path := range r.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// lines returns the file's line numbers, sorted, and how many of them ran.
+func (f *File) lines() (numbers []int, covered int) {
+	for number, hits := range f.Lines {
+		numbers = append(numbers, number)
+		if hits > 0 {
+			covered++
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, covered
+}
+
+// WriteLCOV writes the report as LCOV.
+func (r *Report) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, path := range r.paths() {
+		f := r.Files[path]
+		numbers, covered := f.lines()
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", path)
+		for _, number := range numbers {
+			fmt.Fprintf(bw, "DA:%d,%d\n", number, f.Lines[number])
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(numbers), covered)
+	}
+	return bw.Flush()
+}
+
+// coberturaReport and the types below are the parts of the Cobertura schema
+// that Please reads.
+type coberturaReport struct {
+	XMLName  xml.Name           `xml:"coverage"`
+	LineRate string             `xml:"line-rate,attr"`
+	Version  string             `xml:"version,attr"`
+	Sources  []string           `xml:"sources>source"`
+	Packages []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name     string           `xml:"name,attr"`
+	LineRate string           `xml:"line-rate,attr"`
+	Classes  []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Name     string          `xml:"name,attr"`
+	Filename string          `xml:"filename,attr"`
+	LineRate string          `xml:"line-rate,attr"`
+	Lines    []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int    `xml:"number,attr"`
+	Hits   uint64 `xml:"hits,attr"`
+}
+
+// WriteCobertura writes the report as Cobertura XML, with a class per file and
+// a package per directory.
+func (r *Report) WriteCobertura(w io.Writer) error {
+	type counts struct{ covered, total int }
+	doc := coberturaReport{Version: "please_sol", Sources: []string{"."}}
+	var all counts
+	packages := map[string]*coberturaPackage{}
+	packageCounts := map[string]counts{}
+	for _, path := range r.paths() {
+		f := r.Files[path]
+		numbers, fileCovered := f.lines()
+		class := coberturaClass{Name: path, Filename: path, LineRate: rate(fileCovered, len(numbers))}
+		for _, number := range numbers {
+			class.Lines = append(class.Lines, coberturaLine{Number: number, Hits: f.Lines[number]})
+		}
+
+		dir := "."
+		if i := strings.LastIndex(path, "/"); i >= 0 {
+			dir = path[:i]
+		}
+		pkg, ok := packages[dir]
+		if !ok {
+			pkg = &coberturaPackage{Name: dir}
+			packages[dir] = pkg
+		}
+		pkg.Classes = append(pkg.Classes, class)
+		c := packageCounts[dir]
+		packageCounts[dir] = counts{c.covered + fileCovered, c.total + len(numbers)}
+		all = counts{all.covered + fileCovered, all.total + len(numbers)}
+	}
+	dirs := make([]string, 0, len(packages))
+	for dir := range packages {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		pkg := packages[dir]
+		pkg.LineRate = rate(packageCounts[dir].covered, packageCounts[dir].total)
+		doc.Packages = append(doc.Packages, *pkg)
+	}
+	doc.LineRate = rate(all.covered, all.total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write coverage XML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// rate formats the fraction of lines covered as Cobertura does.
+func rate(covered, total int) string {
+	if total == 0 {
+		return "1"
+	}
+	return strconv.FormatFloat(float64(covered)/float64(total), 'f', 4, 64)
+}
@@ -0,0 +1,100 @@
+package coverage
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// forgeLCOV is trimmed from forge coverage --report lcov.
+const forgeLCOV = `TN:
+SF:src/Counter.sol
+FN:7,Counter.setNumber
+FNDA:2,Counter.setNumber
+DA:8,2
+DA:12,0
+BRDA:8,0,0,1
+LF:2
+LH:1
+end_of_record
+TN:
+SF:lib/openzeppelin/token/ERC20/ERC20.sol
+DA:40,5
+end_of_record
+`
+
+func TestParseLCOV(t *testing.T) {
+	report, err := ParseLCOV(strings.NewReader(forgeLCOV))
+	if err != nil {
+		t.Fatalf("ParseLCOV failed: %v", err)
+	}
+	if len(report.Files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(report.Files))
+	}
+	counter := report.Files["src/Counter.sol"]
+	if counter == nil || len(counter.Lines) != 2 || counter.Lines[8] != 2 || counter.Lines[12] != 0 {
+		t.Errorf("unexpected coverage for src/Counter.sol: %+v", counter)
+	}
+	if _, err := ParseLCOV(strings.NewReader("SF:a.sol\nDA:x,1\n")); err == nil {
+		t.Error("expected an error for an invalid line number")
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	report, err := ParseLCOV(strings.NewReader(forgeLCOV))
+	if err != nil {
+		t.Fatalf("ParseLCOV failed: %v", err)
+	}
+	rewritten := report.Rewrite(func(path string) (string, bool) {
+		if strings.HasPrefix(path, "lib/") {
+			return "", false
+		}
+		return "contracts/" + path, true
+	})
+	if len(rewritten.Files) != 1 || rewritten.Files["contracts/src/Counter.sol"] == nil {
+		t.Errorf("expected only contracts/src/Counter.sol, got %v", rewritten.paths())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, _ := ParseLCOV(strings.NewReader("SF:src/A.sol\nDA:1,1\nDA:2,0\nend_of_record\n"))
+	b, _ := ParseLCOV(strings.NewReader("SF:src/A.sol\nDA:2,3\nend_of_record\nSF:src/B.sol\nDA:5,0\nend_of_record\n"))
+	a.Merge(b)
+
+	var out strings.Builder
+	if err := a.WriteLCOV(&out); err != nil {
+		t.Fatalf("WriteLCOV failed: %v", err)
+	}
+	expected := "TN:\nSF:src/A.sol\nDA:1,1\nDA:2,3\nLF:2\nLH:2\nend_of_record\n" +
+		"TN:\nSF:src/B.sol\nDA:5,0\nLF:1\nLH:0\nend_of_record\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestWriteCobertura(t *testing.T) {
+	report, err := ParseLCOV(strings.NewReader(forgeLCOV))
+	if err != nil {
+		t.Fatalf("ParseLCOV failed: %v", err)
+	}
+	var out strings.Builder
+	if err := report.WriteCobertura(&out); err != nil {
+		t.Fatalf("WriteCobertura failed: %v", err)
+	}
+
+	var doc coberturaReport
+	if err := xml.Unmarshal([]byte(out.String()), &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out.String())
+	}
+	if doc.LineRate != "0.6667" || len(doc.Packages) != 2 {
+		t.Fatalf("unexpected report: %+v", doc)
+	}
+	pkg := doc.Packages[1]
+	if pkg.Name != "src" || pkg.LineRate != "0.5000" {
+		t.Errorf("unexpected package: %+v", pkg)
+	}
+	class := pkg.Classes[0]
+	if class.Filename != "src/Counter.sol" || len(class.Lines) != 2 || class.Lines[0] != (coberturaLine{Number: 8, Hits: 2}) {
+		t.Errorf("unexpected class: %+v", class)
+	}
+}
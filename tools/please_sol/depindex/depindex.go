@@ -0,0 +1,184 @@
+// Package depindex indexes the Solidity files each build target provides and the
+// import paths they're imported by, so that an unresolved import can be traced
+// to the exact target that provides it.
+//
+// A target's files are imported through its remapping if it has one (as sol_get
+// targets do), and by their workspace path otherwise (as sol_library files are).
+package depindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"tools/please_sol/importgraph"
+	"tools/please_sol/solparse"
+)
+
+// Entry is the set of files a target provides under one import prefix.
+type Entry struct {
+	Label string `json:"label"`
+	// Prefix is the import prefix of the target's remapping, or empty if its files
+	// are imported by their workspace paths.
+	Prefix string `json:"prefix,omitempty"`
+	// Files are the files' import paths with Prefix removed, sorted.
+	Files []string `json:"files"`
+}
+
+// Index is every indexed target's entries.
+type Index struct {
+	Entries []Entry `json:"targets"`
+}
+
+// Match is a target that provides an imported file.
+type Match struct {
+	Label string
+	// ImportPath is the path the target provides the file as. It differs from
+	// the path that was imported unless Exact is true.
+	ImportPath string
+	Exact      bool
+}
+
+// Add indexes the files of the target label. paths are its outputs: Solidity
+// files, directories of them, and the .remapping files that say how they're
+// imported. Workspace paths and remapping targets are relative to root.
+func (idx *Index) Add(label, root string, paths []string) error {
+	var remappingFiles, sources []string
+	for _, p := range paths {
+		if strings.HasSuffix(p, ".remapping") {
+			remappingFiles = append(remappingFiles, p)
+		} else {
+			sources = append(sources, p)
+		}
+	}
+	remappings, err := importgraph.LoadRemappingFiles(remappingFiles)
+	if err != nil {
+		return err
+	}
+	files, err := solparse.FindSources(sources)
+	if err != nil {
+		return err
+	}
+
+	entries := map[string]*Entry{}
+	var prefixes []string
+	for _, file := range files {
+		if rel, err := filepath.Rel(root, file); err == nil {
+			file = rel
+		}
+		file = path.Clean(filepath.ToSlash(file))
+		prefix, importPath := "", file
+		for _, r := range remappings {
+			target := strings.TrimSuffix(path.Clean(r.Target), "/") + "/"
+			if rest, ok := strings.CutPrefix(file, target); ok && r.Context == "" {
+				prefix, importPath = r.Prefix, rest
+				break
+			}
+		}
+		e, ok := entries[prefix]
+		if !ok {
+			e = &Entry{Label: label, Prefix: prefix}
+			entries[prefix] = e
+			prefixes = append(prefixes, prefix)
+		}
+		e.Files = append(e.Files, importPath)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		e := entries[prefix]
+		sort.Strings(e.Files)
+		idx.Entries = append(idx.Entries, *e)
+	}
+	return nil
+}
+
+// AddRemappingDir indexes every target with a .remapping file under dir, such
+// as the sol_get rules under plz-out/gen once they've been built. Targets whose
+// files aren't there are skipped.
+func (idx *Index) AddRemappingDir(dir string) error {
+	files, err := importgraph.FindRemappingFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		remappings, err := importgraph.LoadRemappingFiles([]string{file})
+		if err != nil {
+			return err
+		}
+		for _, r := range remappings {
+			target := filepath.Join(dir, filepath.FromSlash(r.Target))
+			if _, err := os.Stat(target); err != nil {
+				continue
+			}
+			if err := idx.Add(r.Label(), dir, []string{file, target}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Lookup returns the targets that provide the file at importPath. If any provide
+// it at exactly that path only they are returned; otherwise targets providing a
+// file whose path ends the same way (e.g. under a different import prefix) are.
+func (idx *Index) Lookup(importPath string) []Match {
+	importPath = path.Clean(importPath)
+	var exact, similar []Match
+	for _, e := range idx.Entries {
+		rest, ok := strings.CutPrefix(importPath, e.Prefix)
+		if ok && contains(e.Files, rest) {
+			exact = append(exact, Match{Label: e.Label, ImportPath: importPath, Exact: true})
+			continue
+		}
+		if file := longestSuffix(e.Files, importPath); file != "" {
+			similar = append(similar, Match{Label: e.Label, ImportPath: e.Prefix + file})
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	return similar
+}
+
+// longestSuffix returns the file that importPath ends with, at a directory
+// boundary, with the most path components. Bare file names don't count, since
+// they're too likely to match by chance.
+func longestSuffix(files []string, importPath string) string {
+	best := ""
+	for _, f := range files {
+		if strings.Contains(f, "/") && strings.HasSuffix(importPath, "/"+f) && len(f) > len(best) {
+			best = f
+		}
+	}
+	return best
+}
+
+func contains(sorted []string, s string) bool {
+	i := sort.SearchStrings(sorted, s)
+	return i < len(sorted) && sorted[i] == s
+}
+
+// Load reads an index in the format ToJSON writes.
+func Load(filename string) (*Index, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dep index: %w", err)
+	}
+	idx := &Index{}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("failed to parse dep index %s: %w", filename, err)
+	}
+	for _, e := range idx.Entries {
+		sort.Strings(e.Files)
+	}
+	return idx, nil
+}
+
+// ToJSON converts the index to indented JSON.
+func (idx *Index) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(idx, "", "  ")
+}
@@ -0,0 +1,128 @@
+package depindex
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles creates the given files, relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"third_party/sol/v3-core.remapping":                      "@uniswap/v3-core/=third_party/sol/v3-core/\n",
+		"third_party/sol/v3-core/contracts/UniswapV3Pool.sol":    "",
+		"third_party/sol/v3-core/contracts/interfaces/IPool.sol": "",
+		"third_party/sol/v3-core/contracts/interfaces/README.md": "",
+		"contracts/lib/Utils.sol":                                "",
+	})
+
+	idx := &Index{}
+	err := idx.Add("//third_party/sol:v3-core", dir, []string{
+		filepath.Join(dir, "third_party/sol/v3-core.remapping"),
+		filepath.Join(dir, "third_party/sol/v3-core"),
+	})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := idx.Add("//contracts/lib:utils", dir, []string{filepath.Join(dir, "contracts/lib/Utils.sol")}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	want := []Entry{
+		{
+			Label:  "//third_party/sol:v3-core",
+			Prefix: "@uniswap/v3-core/",
+			Files:  []string{"contracts/UniswapV3Pool.sol", "contracts/interfaces/IPool.sol"},
+		},
+		{Label: "//contracts/lib:utils", Files: []string{"contracts/lib/Utils.sol"}},
+	}
+	if !reflect.DeepEqual(idx.Entries, want) {
+		t.Errorf("expected %+v, got %+v", want, idx.Entries)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	idx := &Index{Entries: []Entry{
+		{Label: "//third_party/sol:v3-core", Prefix: "@uniswap/v3-core/", Files: []string{"contracts/interfaces/IPool.sol"}},
+		{Label: "//third_party/sol:v3-core-fork", Prefix: "v3-core/", Files: []string{"contracts/interfaces/IPool.sol"}},
+		{Label: "//contracts/lib:utils", Files: []string{"contracts/lib/Utils.sol"}},
+	}}
+
+	tests := []struct {
+		importPath string
+		want       []Match
+	}{
+		{
+			importPath: "@uniswap/v3-core/contracts/interfaces/IPool.sol",
+			want:       []Match{{Label: "//third_party/sol:v3-core", ImportPath: "@uniswap/v3-core/contracts/interfaces/IPool.sol", Exact: true}},
+		},
+		{
+			importPath: "./contracts/lib/Utils.sol",
+			want:       []Match{{Label: "//contracts/lib:utils", ImportPath: "contracts/lib/Utils.sol", Exact: true}},
+		},
+		{
+			// Nothing provides it under this prefix, but two targets have the same file.
+			importPath: "@uniswap-v3/core/contracts/interfaces/IPool.sol",
+			want: []Match{
+				{Label: "//third_party/sol:v3-core", ImportPath: "@uniswap/v3-core/contracts/interfaces/IPool.sol"},
+				{Label: "//third_party/sol:v3-core-fork", ImportPath: "v3-core/contracts/interfaces/IPool.sol"},
+			},
+		},
+		{importPath: "other/IPool.sol"},
+	}
+	for _, tt := range tests {
+		if got := idx.Lookup(tt.importPath); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Lookup(%q): expected %+v, got %+v", tt.importPath, tt.want, got)
+		}
+	}
+}
+
+func TestAddRemappingDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"third_party/solidity/forge-std.remapping":    "forge-std/=third_party/solidity/forge-std/\n",
+		"third_party/solidity/forge-std/src/Test.sol": "",
+		"third_party/solidity/unbuilt.remapping":      "unbuilt/=third_party/solidity/unbuilt/\n",
+	})
+
+	idx := &Index{}
+	if err := idx.AddRemappingDir(dir); err != nil {
+		t.Fatalf("AddRemappingDir failed: %v", err)
+	}
+	want := []Entry{{Label: "//third_party/solidity:forge-std", Prefix: "forge-std/", Files: []string{"src/Test.sol"}}}
+	if !reflect.DeepEqual(idx.Entries, want) {
+		t.Errorf("expected %+v, got %+v", want, idx.Entries)
+	}
+
+	// The index round-trips through JSON.
+	data, err := idx.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	file := filepath.Join(dir, "index.json")
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(file)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Entries, want) {
+		t.Errorf("expected %+v after loading, got %+v", want, loaded.Entries)
+	}
+}
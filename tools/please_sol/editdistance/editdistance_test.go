@@ -0,0 +1,20 @@
+package editdistance
+
+import "testing"
+
+func TestDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "abc", 3},
+		{"optimiser_runs", "optimizer_runs", 1},
+		{"kitten", "sitting", 3},
+		{"rnus", "runs", 1},
+		{"same", "same", 0},
+	} {
+		if got := Distance(tc.a, tc.b); got != tc.want {
+			t.Errorf("Distance(%q, %q): expected %d, got %d", tc.a, tc.b, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package forgewrap
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Severity is how serious a diagnostic is.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Diagnostic is a single error or warning reported by solc or forge.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// Type is solc's error type, e.g. "TypeError" or "ParserError", if it gave one.
+	Type string `json:"type,omitempty"`
+	// Code is solc's numeric error code, e.g. "6275".
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	// Target is the label of the rule that produced File, if it isn't a source in the workspace.
+	Target string `json:"target,omitempty"`
+	// Snippet is the annotated source excerpt solc prints beneath the location.
+	Snippet string `json:"snippet,omitempty"`
+	Hints   []Hint `json:"hints,omitempty"`
+}
+
+// Hint is a suggestion for fixing a diagnostic.
+type Hint struct {
+	Summary     string   `json:"summary"`
+	Detail      string   `json:"detail,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// Location returns "file:line:col", or as much of it as is known.
+func (d Diagnostic) Location() string {
+	if d.File == "" {
+		return ""
+	}
+	if d.Line == 0 {
+		return d.File
+	}
+	return d.File + ":" + strconv.Itoa(d.Line) + ":" + strconv.Itoa(d.Column)
+}
+
+var (
+	ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	// headerPattern matches the first line of a diagnostic, e.g. "Error (6275): ...",
+	// "Warning: ..." or "TypeError: ...".
+	headerPattern   = regexp.MustCompile(`^(Error|Warning|Info|Note|\w+Error|\w+Exception)(?: \((\d+)\))?: ?(.*)$`)
+	locationPattern = regexp.MustCompile(`^\s*--> (.+?):(\d+):(\d+):?\s*$`)
+	snippetPattern  = regexp.MustCompile(`^\s*\d*\s*\|`)
+)
+
+// summaryMessages are forge's framing lines, which aren't diagnostics themselves.
+var summaryMessages = map[string]bool{
+	"Compiler run failed:": true,
+	"Compiler run failed":  true,
+}
+
+// ParseDiagnostics extracts the diagnostics from solc/forge output.
+func ParseDiagnostics(output string) []Diagnostic {
+	var p parser
+	for _, line := range strings.Split(output, "\n") {
+		p.line(line)
+	}
+	return p.finish()
+}
+
+// parser extracts diagnostics from solc/forge output one line at a time.
+type parser struct {
+	diagnostics []Diagnostic
+	current     *Diagnostic
+	snippet     []string
+	// phase, if set, is forge's phase. Once tests run, "Error:" is usually a test's
+	// own assertion output rather than a diagnostic.
+	phase *phaseTracker
+}
+
+// line parses the next line of output, without its trailing newline.
+func (p *parser) line(line string) {
+	line = strings.TrimRight(ansiPattern.ReplaceAllString(line, ""), " \r")
+	if m := headerPattern.FindStringSubmatch(line); m != nil {
+		p.flush()
+		if summaryMessages[m[3]] || (m[1] == "Error" && m[3] == "") {
+			return
+		}
+		if m[1] == "Error" && p.phase != nil && p.phase.get() == PhaseTesting {
+			return
+		}
+		p.current = &Diagnostic{Severity: severity(m[1]), Code: m[2], Message: m[3]}
+		if m[1] != "Error" && m[1] != "Warning" && m[1] != "Info" && m[1] != "Note" {
+			p.current.Type = m[1]
+		}
+		return
+	}
+	current := p.current
+	if current == nil {
+		return
+	}
+	if m := locationPattern.FindStringSubmatch(line); m != nil && current.File == "" {
+		current.File = strings.Trim(m[1], `"`)
+		current.Line, _ = strconv.Atoi(m[2])
+		current.Column, _ = strconv.Atoi(m[3])
+	} else if current.File != "" && snippetPattern.MatchString(line) {
+		p.snippet = append(p.snippet, line)
+	} else if line == "" {
+		if current.File != "" {
+			p.flush()
+		}
+	} else if current.File == "" {
+		current.Message += "\n" + strings.TrimSpace(line)
+	}
+}
+
+// flush completes the diagnostic being parsed, if any.
+func (p *parser) flush() {
+	if p.current != nil {
+		p.current.Snippet = strings.Join(p.snippet, "\n")
+		p.diagnostics = append(p.diagnostics, *p.current)
+	}
+	p.current, p.snippet = nil, nil
+}
+
+// finish completes parsing and returns the diagnostics found.
+func (p *parser) finish() []Diagnostic {
+	p.flush()
+	return p.diagnostics
+}
+
+func severity(kind string) Severity {
+	switch kind {
+	case "Warning":
+		return SeverityWarning
+	case "Info", "Note":
+		return SeverityInfo
+	default:
+		return SeverityError
+	}
+}
+
+// DiagnosticsJSON is the document written by forge-wrap --format=json.
+type DiagnosticsJSON struct {
+	ExitCode    int          `json:"exit_code"`
+	Warnings    int          `json:"warnings"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// ToJSON converts the result's diagnostics to indented JSON.
+func (r *Result) ToJSON() ([]byte, error) {
+	diagnostics := r.Diagnostics
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	return json.MarshalIndent(DiagnosticsJSON{ExitCode: r.ExitCode, Warnings: r.Warnings, Diagnostics: diagnostics}, "", "  ")
+}
@@ -4,9 +4,11 @@ package forgewrap
 import (
 	"bytes"
 	"fmt"
-	"os/exec"
 	"regexp"
 	"strings"
+	"time"
+
+	"tools/please_sol/depindex"
 )
 
 // Result contains the outcome of running a forge command.
@@ -15,120 +17,189 @@ type Result struct {
 	Stdout   string
 	Stderr   string
 	Enhanced string // Enhanced error message if applicable
+	// Diagnostics are the errors and warnings parsed from forge's output, with hints attached.
+	Diagnostics []Diagnostic
+	// Warnings is how many compiler warnings forge reported, and DisallowedWarnings
+	// how many of them failed the command under WarningsError.
+	Warnings           int
+	DisallowedWarnings int
 }
 
 // Wrapper wraps forge commands to provide enhanced error messages.
 type Wrapper struct {
 	remappings []string
+	paths      *PathMap
+	rules      *Rules
+	index      *depindex.Index
+	timeout    time.Duration
+	warnings   WarningPolicy
+	// allowedWarnings are the warning codes allowed under WarningsError.
+	allowedWarnings []string
+	// files caches the files found under each remapping's target.
+	files map[string][]string
 }
 
-// New creates a new Wrapper with the given available remappings.
+// New creates a new Wrapper with the given available remappings and the built-in rules.
 func New(remappings []string) *Wrapper {
-	return &Wrapper{remappings: remappings}
+	return &Wrapper{remappings: remappings, rules: DefaultRules()}
 }
 
-// Run executes a forge command and enhances any error output.
-func (w *Wrapper) Run(forgePath string, args []string) (*Result, error) {
-	cmd := exec.Command(forgePath, args...)
+// SetIndex sets the index of targets used to find the dep that provides an
+// unresolved import.
+func (w *Wrapper) SetIndex(index *depindex.Index) {
+	w.index = index
+}
 
+// SetTimeout sets how long forge may run before it's killed. Zero means no limit.
+func (w *Wrapper) SetTimeout(timeout time.Duration) {
+	w.timeout = timeout
+}
+
+// SetRules sets the rules used to generate hints.
+func (w *Wrapper) SetRules(rules *Rules) {
+	w.rules = rules
+}
+
+// SetPathMap sets the map used to rewrite the paths in forge's output back to
+// workspace paths.
+func (w *Wrapper) SetPathMap(paths *PathMap) {
+	w.paths = paths
+}
+
+// Run executes a forge command and enhances any error output. The output is
+// buffered; use Stream to see it as forge runs.
+func (w *Wrapper) Run(forgePath string, args []string) (*Result, error) {
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	result, err := w.Stream(forgePath, args, &stdout, &stderr)
+	if err != nil {
+		return nil, err
+	}
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	if result.ExitCode != 0 {
+		result.Enhanced = result.Stderr + w.FormatHints(result.Diagnostics)
+	}
+	return result, nil
+}
 
-	err := cmd.Run()
+// Diagnose parses forge output into diagnostics and attaches hints to them.
+// If the command failed but nothing in its output looks like a diagnostic, the
+// whole output is reported as a single error so that it isn't lost.
+func (w *Wrapper) Diagnose(output string, failed bool) []Diagnostic {
+	return w.annotate(ParseDiagnostics(output), output, failed)
+}
 
-	result := &Result{
-		Stdout: stdout.String(),
-		Stderr: stderr.String(),
+// annotate maps the paths in diagnostics and attaches hints to them. output is
+// reported as an error if the command failed without any diagnostics.
+func (w *Wrapper) annotate(diagnostics []Diagnostic, output string, failed bool) []Diagnostic {
+	if failed && len(diagnostics) == 0 && strings.TrimSpace(output) != "" {
+		diagnostics = []Diagnostic{{Severity: SeverityError, Message: strings.TrimSpace(output)}}
+	}
+	for i := range diagnostics {
+		if w.paths != nil {
+			w.paths.apply(&diagnostics[i])
+		}
+		diagnostics[i].Hints = w.hints(diagnostics[i])
 	}
+	return diagnostics
+}
+
+// importPattern matches forge's and solc's messages for an import that can't be found.
+var importPattern = regexp.MustCompile(`Unable to resolve import "([^"]+)"|Source "([^"]+)" not found`)
+
+// hints returns suggestions for fixing a diagnostic.
+func (w *Wrapper) hints(d Diagnostic) []Hint {
+	var hints []Hint
 
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		result.ExitCode = exitErr.ExitCode()
-		result.Enhanced = w.enhanceError(stderr.String())
-	} else if err != nil {
-		return nil, fmt.Errorf("failed to run forge: %w", err)
+	// Check for unresolved import errors
+	for _, match := range importPattern.FindAllStringSubmatch(d.Message, -1) {
+		importPath := match[1] + match[2]
+		hints = append(hints, Hint{
+			Summary:     "Import resolution failed",
+			Detail:      "Import: " + importPath,
+			Suggestions: append(w.suggestPaths(importPath), w.suggestDeps(importPath)...),
+		})
 	}
 
-	return result, nil
+	for i := range w.rules.Hints {
+		if rule := &w.rules.Hints[i]; rule.matches(d) {
+			hints = append(hints, Hint{
+				Summary:     rule.Summary,
+				Suggestions: append(append([]string{}, rule.Suggestions...), rule.Targets...),
+			})
+		}
+	}
+
+	return hints
 }
 
 // enhanceError parses forge error output and adds helpful hints.
 func (w *Wrapper) enhanceError(stderr string) string {
-	var enhanced strings.Builder
-	enhanced.WriteString(stderr)
+	return stderr + w.FormatHints(w.Diagnose(stderr, true))
+}
 
-	// Check for unresolved import errors
-	importPattern := regexp.MustCompile(`Unable to resolve import "([^"]+)"`)
-	matches := importPattern.FindAllStringSubmatch(stderr, -1)
-
-	if len(matches) > 0 {
-		enhanced.WriteString("\n\n" + strings.Repeat("=", 60) + "\n")
-		enhanced.WriteString("HINT: Import resolution failed\n")
-		enhanced.WriteString(strings.Repeat("=", 60) + "\n\n")
-
-		for _, match := range matches {
-			importPath := match[1]
-			enhanced.WriteString(fmt.Sprintf("  Import: %s\n", importPath))
-
-			// Suggest potential deps based on import path
-			suggestions := w.suggestDeps(importPath)
-			if len(suggestions) > 0 {
-				enhanced.WriteString("  Suggested deps:\n")
-				for _, suggestion := range suggestions {
+// FormatHints renders the hints attached to diagnostics as text banners.
+func (w *Wrapper) FormatHints(diagnostics []Diagnostic) string {
+	var enhanced strings.Builder
+	importFailed := false
+	for _, d := range diagnostics {
+		for _, hint := range d.Hints {
+			enhanced.WriteString("\n\n" + strings.Repeat("=", 60) + "\n")
+			enhanced.WriteString("HINT: " + hint.Summary + "\n")
+			enhanced.WriteString(strings.Repeat("=", 60) + "\n\n")
+			if location := d.Location(); location != "" {
+				enhanced.WriteString(fmt.Sprintf("  At: %s\n", location))
+			}
+			if hint.Detail != "" {
+				enhanced.WriteString(fmt.Sprintf("  %s\n", hint.Detail))
+			}
+			if len(hint.Suggestions) > 0 {
+				enhanced.WriteString("  Suggestions:\n")
+				for _, suggestion := range hint.Suggestions {
 					enhanced.WriteString(fmt.Sprintf("    - %s\n", suggestion))
 				}
 			}
-			enhanced.WriteString("\n")
+			importFailed = importFailed || hint.Summary == "Import resolution failed"
 		}
-
-		if len(w.remappings) > 0 {
-			enhanced.WriteString("Available remappings:\n")
-			for _, r := range w.remappings {
-				enhanced.WriteString(fmt.Sprintf("  %s\n", r))
-			}
-		}
-	}
-
-	// Check for compiler version errors
-	if strings.Contains(stderr, "Source file requires different compiler version") {
-		enhanced.WriteString("\n\nHINT: Compiler version mismatch.\n")
-		enhanced.WriteString("Try specifying solc_version in your sol_contract rule.\n")
 	}
 
-	// Check for stack too deep
-	if strings.Contains(stderr, "Stack too deep") {
-		enhanced.WriteString("\n\nHINT: Stack too deep error.\n")
-		enhanced.WriteString("Consider:\n")
-		enhanced.WriteString("  - Breaking up the function into smaller functions\n")
-		enhanced.WriteString("  - Using structs to group variables\n")
-		enhanced.WriteString("  - Enabling optimizer with higher runs\n")
+	if importFailed && len(w.remappings) > 0 {
+		enhanced.WriteString("\nAvailable remappings:\n")
+		for _, r := range w.remappings {
+			enhanced.WriteString(fmt.Sprintf("  %s\n", r))
+		}
 	}
 
 	return enhanced.String()
 }
 
+// FormatResult renders what forge-wrap prints after forge's own output in text
+// mode: the hints for every diagnostic that has them, which includes warnings a
+// rule matches even if forge succeeded, then the summary of warnings.
+func (w *Wrapper) FormatResult(result *Result) string {
+	return w.FormatHints(result.Diagnostics) + w.FormatWarnings(result)
+}
+
 // suggestDeps suggests deps based on an import path.
 func (w *Wrapper) suggestDeps(importPath string) []string {
 	var suggestions []string
 
-	// Map common prefixes to deps
-	prefixToDep := map[string]string{
-		"@openzeppelin/contracts": "openzeppelin-contracts",
-		"@openzeppelin":           "openzeppelin-contracts",
-		"openzeppelin-contracts":  "openzeppelin-contracts",
-		"forge-std":               "forge-std",
-		"solmate":                 "solmate",
-		"@solmate":                "solmate",
-		"@rari-capital/solmate":   "solmate",
-		"solady":                  "solady",
-	}
-
-	for prefix, dep := range prefixToDep {
-		if strings.HasPrefix(importPath, prefix) {
-			suggestions = append(suggestions, fmt.Sprintf("//third_party/solidity:%s", dep))
-			break
+	// Suggest the targets the index says provide the file. If none provide it at
+	// exactly this path, fall back to the import rules.
+	exact := false
+	if w.index != nil {
+		for _, match := range w.index.Lookup(importPath) {
+			if match.Exact {
+				exact = true
+				suggestions = append(suggestions, match.Label)
+			} else {
+				suggestions = append(suggestions, fmt.Sprintf("%s (provides it as %s)", match.Label, match.ImportPath))
+			}
 		}
 	}
+	if !exact {
+		suggestions = append(suggestions, w.rules.importTargets(importPath)...)
+	}
 
 	// Also check current remappings for partial matches
 	for _, remap := range w.remappings {
@@ -1,8 +1,17 @@
 package forgewrap
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"tools/please_sol/depindex"
 )
 
 func TestEnhanceError_ImportResolution(t *testing.T) {
@@ -176,3 +185,634 @@ func TestSuggestDeps(t *testing.T) {
 		})
 	}
 }
+
+const solcOutput = `Compiling 2 files with Solc 0.8.20
+Error: Compiler run failed:
+Error (6275): Source "forge-std/Test.sol" not found: File not found. Searched the following locations: "".
+ParserError: Source "forge-std/Test.sol" not found: File not found.
+ --> test/Counter.t.sol:4:1:
+  |
+4 | import "forge-std/Test.sol";
+  | ^^^^^^^^^^^^^^^^^^^^^^^^^^^^
+
+Warning (2072): Unused local variable.
+  --> src/Counter.sol:10:9:
+   |
+10 |         uint256 x = 1;
+   |         ^^^^^^^^^
+`
+
+func TestParseDiagnostics(t *testing.T) {
+	diagnostics := ParseDiagnostics("\x1b[31m" + solcOutput + "\x1b[0m")
+	if len(diagnostics) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %d: %+v", len(diagnostics), diagnostics)
+	}
+
+	if d := diagnostics[0]; d.Severity != SeverityError || d.Code != "6275" || d.File != "" {
+		t.Errorf("unexpected first diagnostic: %+v", d)
+	}
+
+	d := diagnostics[1]
+	if d.Severity != SeverityError || d.Type != "ParserError" || d.Code != "" {
+		t.Errorf("unexpected severity/type/code: %+v", d)
+	}
+	if d.Message != `Source "forge-std/Test.sol" not found: File not found.` {
+		t.Errorf("unexpected message: %q", d.Message)
+	}
+	if d.Location() != "test/Counter.t.sol:4:1" {
+		t.Errorf("expected location test/Counter.t.sol:4:1, got %q", d.Location())
+	}
+	wantSnippet := "  |\n4 | import \"forge-std/Test.sol\";\n  | ^^^^^^^^^^^^^^^^^^^^^^^^^^^^"
+	if d.Snippet != wantSnippet {
+		t.Errorf("expected snippet:\n%s\ngot:\n%s", wantSnippet, d.Snippet)
+	}
+
+	d = diagnostics[2]
+	if d.Severity != SeverityWarning || d.Code != "2072" || d.Message != "Unused local variable." {
+		t.Errorf("unexpected warning: %+v", d)
+	}
+	if d.File != "src/Counter.sol" || d.Line != 10 || d.Column != 9 {
+		t.Errorf("unexpected warning location: %q", d.Location())
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	w := New([]string{"forge-std/=third_party/solidity/forge-std/"})
+	diagnostics := w.Diagnose(solcOutput, true)
+	if len(diagnostics) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %d", len(diagnostics))
+	}
+	for _, d := range diagnostics[:2] {
+		if len(d.Hints) != 1 || d.Hints[0].Detail != "Import: forge-std/Test.sol" {
+			t.Errorf("expected an import hint on %q, got %+v", d.Message, d.Hints)
+		} else if !strings.Contains(strings.Join(d.Hints[0].Suggestions, "\n"), "//third_party/solidity:forge-std") {
+			t.Errorf("expected forge-std dep suggestion, got %v", d.Hints[0].Suggestions)
+		}
+	}
+	if len(diagnostics[2].Hints) != 0 {
+		t.Errorf("expected no hints on warning, got %+v", diagnostics[2].Hints)
+	}
+
+	// Unrecognised output from a failed command is reported as a single error.
+	diagnostics = w.Diagnose("something went wrong\n", true)
+	if len(diagnostics) != 1 || diagnostics[0].Message != "something went wrong" {
+		t.Errorf("expected a single fallback diagnostic, got %+v", diagnostics)
+	}
+	if diagnostics = w.Diagnose("all good\n", false); len(diagnostics) != 0 {
+		t.Errorf("expected no diagnostics for successful run, got %+v", diagnostics)
+	}
+}
+
+func TestDiagnose_DefaultRules(t *testing.T) {
+	tests := map[string]string{
+		"Warning (5574): Contract code size is 30412 bytes and exceeds 24576 bytes (a limit introduced in Spurious Dragon).":                 "Contract exceeds the EIP-170 code size limit of 24576 bytes.",
+		"Error: some contracts exceed the runtime size limit (EIP-170: 24576 bytes)":                                                         "Contract exceeds the EIP-170 code size limit of 24576 bytes.",
+		"Warning (3860): Contract initcode size is 50112 bytes and exceeds 49152 bytes (a limit introduced in Shanghai).":                    "Contract exceeds the EIP-3860 initcode size limit of 49152 bytes.",
+		"Warning (1878): SPDX license identifier not provided in source file.":                                                               "Missing SPDX license identifier.",
+		"DeclarationError (7920): Identifier not found or not unique.":                                                                       "Identifier not found or not unique.",
+		`TypeError (9582): Member "toEthSignedMessageHash" not found or not visible after argument-dependent lookup in type(library ECDSA).`: "Member not found or not visible.",
+		"YulException: Variable var_amount is 1 slot(s) too deep inside the stack.":                                                          "via-IR code generation failed.",
+		"Error: Dynamic linking not supported in `create` command - deploy the following library contracts first":                            "Contract uses libraries that aren't linked.",
+	}
+	w := New(nil)
+	for output, want := range tests {
+		diagnostics := w.Diagnose(output, true)
+		if len(diagnostics) != 1 || len(diagnostics[0].Hints) != 1 {
+			t.Errorf("expected one diagnostic with one hint for %q, got %+v", output, diagnostics)
+		} else if got := diagnostics[0].Hints[0].Summary; got != want {
+			t.Errorf("%q: expected hint %q, got %q", output, want, got)
+		}
+	}
+}
+
+func TestFormatResult_WarningHints(t *testing.T) {
+	forge := fakeForge(t, `cat >&2 <<'EOF'
+Compiling 1 files with Solc 0.8.20
+Compiler run successful with warnings:
+Warning (5574): Contract code size is 30412 bytes and exceeds 24576 bytes (a limit introduced in Spurious Dragon).
+ --> src/Big.sol:4:1:
+
+EOF
+`)
+	w := New(nil)
+	result, err := w.Stream(forge, []string{"build"}, io.Discard, io.Discard)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("expected forge to succeed, got exit code %d", result.ExitCode)
+	}
+	output := w.FormatResult(result)
+	if !strings.Contains(output, "HINT: Contract exceeds the EIP-170 code size limit of 24576 bytes.") || !strings.Contains(output, "At: src/Big.sol:4:1") {
+		t.Errorf("expected the EIP-170 hint for the warning, got:\n%s", output)
+	}
+	if !strings.Contains(output, "1 compiler warning (5574 x1)") {
+		t.Errorf("expected the warnings summary, got:\n%s", output)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	result := &Result{ExitCode: 1, Diagnostics: New(nil).Diagnose("CompilerError: Stack too deep.", true)}
+	data, err := result.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	var decoded DiagnosticsJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	if decoded.ExitCode != 1 || len(decoded.Diagnostics) != 1 {
+		t.Fatalf("unexpected document: %s", data)
+	}
+	if d := decoded.Diagnostics[0]; d.Type != "CompilerError" || len(d.Hints) != 1 || d.Hints[0].Summary != "Stack too deep error." {
+		t.Errorf("unexpected diagnostic: %+v", d)
+	}
+
+	empty, _ := (&Result{}).ToJSON()
+	if !strings.Contains(string(empty), `"diagnostics": []`) {
+		t.Errorf("expected an empty diagnostics list, got %s", empty)
+	}
+}
+
+func TestPathMap(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "paths")
+	content := "# sol_contract sources\nsrc/Counter.sol contracts/core/Counter.sol\nsrc/ contracts/\n\n"
+	if err := os.WriteFile(manifest, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := NewPathMap("/tmp/plz-out/tmp/contracts/_counter#forge_build._build")
+	if err := p.LoadManifest(manifest); err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	p.AddRemappings([]string{"forge-std/=third_party/solidity/forge-std/", "abs/=/opt/abs/"}, "")
+
+	tests := []struct {
+		path, want, wantTarget string
+		wantOK                 bool
+	}{
+		{"src/Counter.sol", "contracts/core/Counter.sol", "", true},
+		{"./src/Token.sol", "contracts/Token.sol", "", true},
+		{"/tmp/plz-out/tmp/contracts/_counter#forge_build._build/src/Token.sol", "contracts/Token.sol", "", true},
+		{"third_party/solidity/forge-std/src/Test.sol", "plz-out/gen/third_party/solidity/forge-std/src/Test.sol", "//third_party/solidity:forge-std", true},
+		{"test/Other.t.sol", "test/Other.t.sol", "", false},
+	}
+	for _, tt := range tests {
+		got, target, ok := p.Map(tt.path)
+		if got != tt.want || target != tt.wantTarget || ok != tt.wantOK {
+			t.Errorf("Map(%q): expected %q, %q, %v, got %q, %q, %v", tt.path, tt.want, tt.wantTarget, tt.wantOK, got, target, ok)
+		}
+	}
+
+	output := "Error (7576): Undeclared identifier.\n --> src/Counter.sol:5:16:\nsee ./lib/Other.sol and third_party/solidity/forge-std/src/Vm.sol"
+	want := "Error (7576): Undeclared identifier.\n --> contracts/core/Counter.sol:5:16:\nsee ./lib/Other.sol and plz-out/gen/third_party/solidity/forge-std/src/Vm.sol"
+	if got := p.Rewrite(output); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	if err := os.WriteFile(manifest, []byte("src/ contracts/ //a:b extra\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewPathMap().LoadManifest(manifest); err == nil {
+		t.Errorf("expected an error for a malformed manifest line")
+	}
+}
+
+func TestDiagnose_PathMap(t *testing.T) {
+	remappings := []string{"forge-std/=third_party/solidity/forge-std/"}
+	p := NewPathMap()
+	p.Add("src/", "contracts/", "")
+	p.AddRemappings(remappings, "")
+	w := New(remappings)
+	w.SetPathMap(p)
+
+	output := "Error (2314): Expected ';' but got '}'\n --> third_party/solidity/forge-std/src/Test.sol:3:1:\n\nWarning: Unused variable in src/Counter.sol\n --> src/Counter.sol:1:1:\n"
+	diagnostics := w.Diagnose(output, true)
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %+v", diagnostics)
+	}
+	if d := diagnostics[0]; d.File != "plz-out/gen/third_party/solidity/forge-std/src/Test.sol" || d.Target != "//third_party/solidity:forge-std" {
+		t.Errorf("unexpected file/target: %q, %q", d.File, d.Target)
+	}
+	if d := diagnostics[1]; d.File != "contracts/Counter.sol" || d.Target != "" || d.Message != "Unused variable in contracts/Counter.sol" {
+		t.Errorf("unexpected diagnostic: %+v", d)
+	}
+}
+
+func TestRemappingsFromArgs(t *testing.T) {
+	args := []string{"build", "--remappings", "a/=b/", "--remappings=c/=d/", "--root", ".", "--remappings"}
+	got := RemappingsFromArgs(args)
+	if strings.Join(got, " ") != "a/=b/ c/=d/" {
+		t.Errorf("expected [a/=b/ c/=d/], got %v", got)
+	}
+}
+
+// fakeForge writes a shell script that stands in for forge.
+func fakeForge(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "forge")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// signalWriter closes ready the first time it's written to.
+type signalWriter struct {
+	out   strings.Builder
+	ready chan struct{}
+}
+
+func (w *signalWriter) Write(p []byte) (int, error) {
+	if w.out.Len() == 0 {
+		close(w.ready)
+	}
+	return w.out.Write(p)
+}
+
+func TestStream(t *testing.T) {
+	// forge waits for its first line to be seen before printing the rest, so this
+	// only passes if output is streamed.
+	marker := filepath.Join(t.TempDir(), "seen")
+	forge := fakeForge(t, `echo "Compiling 1 files"
+i=0; while [ ! -f "`+marker+`" ] && [ $i -lt 500 ]; do sleep 0.01; i=$((i+1)); done
+[ -f "`+marker+`" ] || exit 3
+echo 'Error (7576): Undeclared identifier.' >&2
+echo ' --> src/Counter.sol:5:16:' >&2
+exit 1
+`)
+	p := NewPathMap()
+	p.Add("src/", "contracts/", "")
+	w := New(nil)
+	w.SetPathMap(p)
+
+	stdout := &signalWriter{ready: make(chan struct{})}
+	go func() {
+		<-stdout.ready
+		os.WriteFile(marker, nil, 0644)
+	}()
+	var stderr strings.Builder
+	result, err := w.Stream(forge, nil, stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if result.ExitCode != 1 {
+		t.Fatalf("expected exit code 1, got %d (was output buffered?)", result.ExitCode)
+	}
+	if stdout.out.String() != "Compiling 1 files\n" {
+		t.Errorf("unexpected stdout: %q", stdout.out.String())
+	}
+	if stderr.String() != "Error (7576): Undeclared identifier.\n --> contracts/Counter.sol:5:16:\n" {
+		t.Errorf("unexpected stderr: %q", stderr.String())
+	}
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Location() != "contracts/Counter.sol:5:16" {
+		t.Errorf("unexpected diagnostics: %+v", result.Diagnostics)
+	}
+	if result.Stdout != "" || result.Stderr != "" {
+		t.Errorf("expected Stream not to keep the output, got %q, %q", result.Stdout, result.Stderr)
+	}
+}
+
+func TestStream_TestErrors(t *testing.T) {
+	forge := fakeForge(t, `cat <<'EOF'
+Compiling 2 files with Solc 0.8.20
+Compiler run successful!
+
+Ran 1 test for test/Counter.t.sol:CounterTest
+[FAIL: assertion failed] testIncrement() (gas: 8911)
+Logs:
+Error: a == b not satisfied [uint]
+      Left: 1
+     Right: 2
+EOF
+exit 1
+`)
+	result, err := New(nil).Stream(forge, []string{"test"}, io.Discard, io.Discard)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	// The test's own error isn't a diagnostic, so the output is reported as a whole.
+	if len(result.Diagnostics) != 1 || !strings.HasPrefix(result.Diagnostics[0].Message, "Compiling 2 files") {
+		t.Errorf("expected only the fallback diagnostic, got %+v", result.Diagnostics)
+	}
+}
+
+func TestStream_ErrorsBeforeCompiling(t *testing.T) {
+	// forge test --json doesn't print a "Compiling" line before the errors.
+	forge := fakeForge(t, `cat >&2 <<'EOF'
+Warning (2072): Unused local variable.
+ --> src/Counter.sol:5:9:
+
+Error (7576): Undeclared identifier.
+ --> src/Counter.sol:9:16:
+EOF
+exit 1
+`)
+	result, err := New(nil).Stream(forge, []string{"test", "--json"}, io.Discard, io.Discard)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if len(result.Diagnostics) != 2 {
+		t.Fatalf("expected the warning and the error, got %+v", result.Diagnostics)
+	}
+	if d := result.Diagnostics[1]; d.Severity != SeverityError || d.Code != "7576" || d.Location() != "src/Counter.sol:9:16" {
+		t.Errorf("unexpected error diagnostic: %+v", d)
+	}
+}
+
+func TestRun(t *testing.T) {
+	forge := fakeForge(t, `echo out; printf 'Error: Unable to resolve import "forge-std/Test.sol"' >&2; exit 2`)
+	result, err := New(nil).Run(forge, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.ExitCode != 2 || result.Stdout != "out\n" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if !strings.HasPrefix(result.Enhanced, result.Stderr) || !strings.Contains(result.Enhanced, "HINT: Import resolution failed") {
+		t.Errorf("unexpected enhanced output:\n%s", result.Enhanced)
+	}
+
+	result, err = New(nil).Run(fakeForge(t, "echo ok"), nil)
+	if err != nil || result.ExitCode != 0 || result.Enhanced != "" || len(result.Diagnostics) != 0 {
+		t.Errorf("unexpected result for successful run: %+v, %v", result, err)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules([]byte(`
+[[hint]]
+pattern = "Stack too deep"
+summary = "Use via_ir."
+targets = ["//tools:via_ir"]
+
+[[hint]]
+code = "2072"
+summary = "Unused variable."
+
+[[import]]
+prefix = "@openzeppelin/contracts/"
+targets = ["//third_party/sol:openzeppelin"]
+
+[[import]]
+prefix = "@acme/"
+targets = ["//libs/acme:contracts", "//libs/acme:interfaces"]
+`))
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+	w := New(nil)
+	w.SetRules(rules)
+
+	hints := w.hints(Diagnostic{Type: "CompilerError", Message: "Stack too deep."})
+	if len(hints) != 2 || hints[0].Summary != "Use via_ir." || hints[1].Summary != "Stack too deep error." {
+		t.Fatalf("expected the custom hint before the built-in one, got %+v", hints)
+	}
+	if strings.Join(hints[0].Suggestions, ",") != "//tools:via_ir" {
+		t.Errorf("expected the rule's targets as suggestions, got %v", hints[0].Suggestions)
+	}
+	if hints := w.hints(Diagnostic{Code: "2072", Message: "Unused local variable."}); len(hints) != 1 {
+		t.Errorf("expected a hint matching the error code, got %+v", hints)
+	}
+
+	tests := map[string]string{
+		"@openzeppelin/contracts/token/ERC20/ERC20.sol": "//third_party/sol:openzeppelin",
+		"@openzeppelin/contracts-upgradeable/Foo.sol":   "//third_party/solidity:openzeppelin-contracts",
+		"@acme/Token.sol":    "//libs/acme:contracts,//libs/acme:interfaces",
+		"forge-std/Test.sol": "//third_party/solidity:forge-std",
+	}
+	for importPath, want := range tests {
+		if got := strings.Join(w.suggestDeps(importPath), ","); got != want {
+			t.Errorf("suggestDeps(%q): expected %s, got %s", importPath, want, got)
+		}
+	}
+
+	rules, err = ParseRules([]byte("replace_default_hints = true\nreplace_default_imports = true\n"))
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+	if len(rules.Hints) != 0 || len(rules.Imports) != 0 {
+		t.Errorf("expected the built-in rules to be dropped, got %+v", rules)
+	}
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	for _, data := range []string{
+		"[[hint]]\nsummary = \"no pattern\"\n",
+		"[[hint]]\npattern = \"(\"\nsummary = \"bad regexp\"\n",
+		"[[import]]\nprefix = \"a/\"\n",
+		"[[hint]]\npatern = \"typo\"\nsummary = \"x\"\n",
+	} {
+		if _, err := ParseRules([]byte(data)); err == nil {
+			t.Errorf("expected an error for:\n%s", data)
+		}
+	}
+}
+
+func TestSuggestDeps_Index(t *testing.T) {
+	w := New(nil)
+	w.SetIndex(&depindex.Index{Entries: []depindex.Entry{
+		{Label: "//third_party/sol:v3-core", Prefix: "@uniswap/v3-core/", Files: []string{"contracts/interfaces/IPool.sol"}},
+		{Label: "//third_party/sol:oz", Prefix: "@openzeppelin/contracts/", Files: []string{"token/ERC20/ERC20.sol"}},
+	}})
+
+	tests := map[string]string{
+		// The index knows the exact target, so the built-in guess isn't made.
+		"@openzeppelin/contracts/token/ERC20/ERC20.sol":   "//third_party/sol:oz",
+		"@uniswap/v3-core/contracts/interfaces/IPool.sol": "//third_party/sol:v3-core",
+		"v3-core/contracts/interfaces/IPool.sol":          "//third_party/sol:v3-core (provides it as @uniswap/v3-core/contracts/interfaces/IPool.sol)",
+		// A file the index doesn't have falls back to the import rules.
+		"@openzeppelin/contracts/access/Ownable.sol": "//third_party/solidity:openzeppelin-contracts",
+	}
+	for importPath, want := range tests {
+		if got := strings.Join(w.suggestDeps(importPath), ","); got != want {
+			t.Errorf("suggestDeps(%q): expected %s, got %s", importPath, want, got)
+		}
+	}
+}
+
+func TestSuggestPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"token/ERC20/ERC20.sol", "token/ERC20/IERC20.sol", "utils/ReentrancyGuard.sol", "utils/Pausable.sol"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := New([]string{"@openzeppelin/contracts/=" + dir + "/"})
+
+	tests := map[string][]string{
+		"@openzeppelin/contracts/token/ERC20/ERC20s.sol": {
+			`Did you mean "@openzeppelin/contracts/token/ERC20/ERC20.sol"?`,
+			`Did you mean "@openzeppelin/contracts/token/ERC20/IERC20.sol"?`,
+		},
+		// A known move isn't suggested again as a similar path.
+		"@openzeppelin/contracts/security/ReentrancyGuard.sol": {
+			`Did you mean "@openzeppelin/contracts/utils/ReentrancyGuard.sol"? It moved there in OpenZeppelin 5.0`,
+		},
+		"@openzeppelin/contracts/utils/Counters.sol": {
+			`"@openzeppelin/contracts/utils/Counters.sol" was removed in OpenZeppelin 5.0`,
+		},
+		// Existing files aren't misspelt.
+		"@openzeppelin/contracts/token/ERC20/ERC20.sol": nil,
+		"forge-std/Test.sol":                            nil,
+	}
+	for importPath, want := range tests {
+		if got := w.suggestPaths(importPath); strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("suggestPaths(%q): expected %q, got %q", importPath, want, got)
+		}
+	}
+}
+
+func TestSuggestPaths_Rename(t *testing.T) {
+	rules, err := ParseRules([]byte(`
+[[rename]]
+from = "v1/Old.sol"
+to = "v2/New.sol"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := New(nil)
+	w.SetRules(rules)
+
+	// Without a remapping to check against, the move is suggested as is.
+	got := strings.Join(w.suggestPaths("acme/v1/Old.sol"), "\n")
+	if want := `Did you mean "acme/v2/New.sol"? It moved there`; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := w.suggestPaths("acme/v1/Older.sol"); len(got) != 0 {
+		t.Errorf("expected no suggestions, got %q", got)
+	}
+}
+
+func TestStream_Timeout(t *testing.T) {
+	tests := map[string]struct {
+		args   []string
+		output string
+		phase  Phase
+	}{
+		"starting":  {output: "", phase: PhaseStarting},
+		"compiling": {args: []string{"build"}, output: "[⠒] Compiling 2 files with Solc 0.8.20", phase: PhaseCompiling},
+		"testing":   {args: []string{"test"}, output: "Compiler run successful!", phase: PhaseTesting},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The child stands in for solc. It holds forge's output open, so Stream only
+			// returns in time if it's killed along with forge.
+			forge := fakeForge(t, `echo "`+test.output+`"
+sleep 30 &
+wait
+`)
+			w := New(nil)
+			w.SetTimeout(200 * time.Millisecond)
+			start := time.Now()
+			result, err := w.Stream(forge, test.args, io.Discard, io.Discard)
+			if err != nil {
+				t.Fatalf("Stream failed: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("expected forge's children to be killed, but Stream took %s", elapsed)
+			}
+			if result.ExitCode != 128+int(syscall.SIGKILL) {
+				t.Errorf("expected exit code %d, got %d", 128+int(syscall.SIGKILL), result.ExitCode)
+			}
+			want := "forge timed out after 200ms while " + string(test.phase)
+			if len(result.Diagnostics) != 1 || result.Diagnostics[0].Message != want {
+				t.Fatalf("expected a single diagnostic %q, got %+v", want, result.Diagnostics)
+			}
+			if len(result.Diagnostics[0].Hints) != 1 {
+				t.Errorf("expected a timeout hint, got %+v", result.Diagnostics[0].Hints)
+			}
+		})
+	}
+}
+
+func TestStream_Signal(t *testing.T) {
+	forge := fakeForge(t, `trap 'echo stopped; exit 5' TERM
+echo "Compiling 1 files"
+sleep 30 &
+wait
+`)
+	w := New(nil)
+	stdout := &signalWriter{ready: make(chan struct{})}
+	go func() {
+		// forge's output is only read once signals are being forwarded.
+		<-stdout.ready
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	}()
+	result, err := w.Stream(forge, nil, stdout, io.Discard)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if result.ExitCode != 5 {
+		t.Errorf("expected forge's exit code 5, got %d", result.ExitCode)
+	}
+	if !strings.Contains(stdout.out.String(), "stopped") {
+		t.Errorf("expected forge to get the signal, got output %q", stdout.out.String())
+	}
+	want := "forge was stopped by SIGTERM while compiling"
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Message != want {
+		t.Errorf("expected a single diagnostic %q, got %+v", want, result.Diagnostics)
+	}
+}
+
+func TestStream_Warnings(t *testing.T) {
+	forge := fakeForge(t, `cat >&2 <<'EOF'
+Warning (2072): Unused local variable.
+ --> src/Counter.sol:5:9:
+
+Warning (5667): Unused function parameter. Remove or comment out the variable name to silence this warning.
+ --> src/Counter.sol:9:22:
+
+Warning (2072): Unused local variable.
+ --> lib/oz/token/ERC20.sol:3:9:
+
+Warning: This is a nightly build of forge.
+EOF
+`)
+	p := NewPathMap()
+	p.Add("lib/oz/", "plz-out/gen/third_party/sol/oz/", "//third_party/sol:oz")
+
+	tests := []struct {
+		policy     WarningPolicy
+		allowed    []string
+		exitCode   int
+		reported   int
+		disallowed int
+	}{
+		{policy: WarningsIgnore, exitCode: 0, reported: 0},
+		{policy: WarningsReport, exitCode: 0, reported: 4},
+		// Warnings in sol_get code and forge's own warnings are always allowed.
+		{policy: WarningsError, exitCode: WarningsExitCode, reported: 4, disallowed: 2},
+		{policy: WarningsError, allowed: []string{"2072"}, exitCode: WarningsExitCode, reported: 4, disallowed: 1},
+		{policy: WarningsError, allowed: []string{"2072", "5667"}, exitCode: 0, reported: 4},
+	}
+	for _, test := range tests {
+		w := New(nil)
+		w.SetPathMap(p)
+		w.SetWarnings(test.policy, test.allowed)
+		result, err := w.Stream(forge, nil, io.Discard, io.Discard)
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		name := fmt.Sprintf("%s %v", test.policy, test.allowed)
+		if result.ExitCode != test.exitCode {
+			t.Errorf("%s: expected exit code %d, got %d", name, test.exitCode, result.ExitCode)
+		}
+		if result.Warnings != 4 || result.DisallowedWarnings != test.disallowed {
+			t.Errorf("%s: expected 4 warnings with %d disallowed, got %d with %d", name, test.disallowed, result.Warnings, result.DisallowedWarnings)
+		}
+		if len(result.Diagnostics) != test.reported {
+			t.Errorf("%s: expected %d diagnostics, got %+v", name, test.reported, result.Diagnostics)
+		}
+		summary := w.FormatWarnings(result)
+		if test.policy != WarningsIgnore && !strings.Contains(summary, "4 compiler warnings (2072 x2, 5667 x1)") {
+			t.Errorf("%s: unexpected summary %q", name, summary)
+		}
+		if test.disallowed > 0 && !strings.Contains(summary, "not allowed") {
+			t.Errorf("%s: expected the summary to say warnings weren't allowed, got %q", name, summary)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package forgewrap
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"tools/please_sol/importgraph"
+)
+
+// PathMap maps the paths forge reports in a build's working directory back to
+// the workspace paths they were copied from.
+type PathMap struct {
+	// roots are the absolute directories forge ran in; paths under them are made relative first.
+	roots   []string
+	entries []pathEntry
+}
+
+// pathEntry maps a file, or a directory if from ends in "/", to a workspace path.
+type pathEntry struct {
+	from, to string
+	// target is the build label that produced the files, if they aren't sources in the workspace.
+	target string
+}
+
+// NewPathMap returns an empty PathMap for forge running in the given directories.
+func NewPathMap(roots ...string) *PathMap {
+	p := &PathMap{}
+	for _, root := range roots {
+		if root != "" {
+			p.roots = append(p.roots, strings.TrimSuffix(root, "/")+"/")
+		}
+	}
+	return p
+}
+
+// Add maps from to to. Directories must end in "/"; "./" maps to the workspace root.
+func (p *PathMap) Add(from, to, target string) {
+	p.entries = append(p.entries, pathEntry{from: cleanPath(from), to: cleanPath(to), target: target})
+}
+
+// LoadManifest adds the mappings from a manifest file: one "from to [target]" per
+// line, with blank lines and lines starting with # ignored.
+func (p *PathMap) LoadManifest(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open path map: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return fmt.Errorf("%s:%d: expected \"from to [target]\", got %q", filename, n, line)
+		}
+		p.Add(fields[0], fields[1], strings.Join(fields[2:], ""))
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read path map: %w", err)
+	}
+	return nil
+}
+
+// AddRemappings maps the targets of sol_get remappings ("prefix=pkg/name/") to
+// where Please writes them under genDir, labelled with the sol_get rule.
+func (p *PathMap) AddRemappings(remappings []string, genDir string) {
+	if genDir == "" {
+		genDir = importgraph.DefaultGenDir
+	}
+	for _, remapping := range remappings {
+		_, target, ok := strings.Cut(remapping, "=")
+		target = cleanPath(target)
+		if !ok || target == "" || path.IsAbs(target) || strings.HasPrefix(target, "..") {
+			continue
+		}
+		dir := strings.TrimSuffix(target, "/")
+		label := ""
+		if pkg, name := path.Split(dir); name != "" {
+			label = "//" + strings.TrimSuffix(pkg, "/") + ":" + name
+		}
+		p.Add(dir+"/", path.Join(genDir, dir)+"/", label)
+	}
+}
+
+// Map returns the workspace path for a path forge reported, and the label of the
+// rule that produced it if it isn't a workspace source. ok is false if no
+// mapping applies.
+func (p *PathMap) Map(filename string) (mapped, target string, ok bool) {
+	if p == nil {
+		return filename, "", false
+	}
+	filename = p.relative(filename)
+	// Exact matches win, then the longest directory prefix.
+	best := -1
+	for i, e := range p.entries {
+		if e.from == filename {
+			return e.to, e.target, true
+		}
+		if strings.HasSuffix(e.from, "/") && strings.HasPrefix(filename, e.from) &&
+			(best < 0 || len(e.from) > len(p.entries[best].from)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return filename, "", false
+	}
+	e := p.entries[best]
+	return e.to + strings.TrimPrefix(filename, e.from), e.target, true
+}
+
+// relative strips a root directory and leading "./" from filename.
+func (p *PathMap) relative(filename string) string {
+	for _, root := range p.roots {
+		if rel, ok := strings.CutPrefix(filename, root); ok {
+			return cleanPath(rel)
+		}
+	}
+	return cleanPath(filename)
+}
+
+// solPathPattern matches the Solidity file paths that appear in forge output.
+var solPathPattern = regexp.MustCompile(`[\w@.+/-]*[\w@+-]\.sol\b`)
+
+// Rewrite replaces every mapped Solidity file path in output with its workspace path.
+func (p *PathMap) Rewrite(output string) string {
+	if p == nil || (len(p.entries) == 0 && len(p.roots) == 0) {
+		return output
+	}
+	return solPathPattern.ReplaceAllStringFunc(output, func(s string) string {
+		if mapped, _, ok := p.Map(s); ok || path.IsAbs(s) {
+			return mapped
+		}
+		// Leave unmapped relative paths exactly as forge wrote them.
+		return s
+	})
+}
+
+// apply rewrites the paths in a diagnostic and records the rule its file came from.
+func (p *PathMap) apply(d *Diagnostic) {
+	if d.File != "" {
+		d.File, d.Target, _ = p.Map(d.File)
+	}
+	d.Message = p.Rewrite(d.Message)
+}
+
+// RemappingsFromArgs returns the values of the --remappings flags in forge arguments.
+func RemappingsFromArgs(args []string) []string {
+	var remappings []string
+	for i, arg := range args {
+		if value, ok := strings.CutPrefix(arg, "--remappings="); ok {
+			remappings = append(remappings, value)
+		} else if arg == "--remappings" && i+1 < len(args) {
+			remappings = append(remappings, args[i+1])
+		}
+	}
+	return remappings
+}
+
+// cleanPath strips a leading "./", keeping any trailing slash.
+func cleanPath(s string) string {
+	for strings.HasPrefix(s, "./") {
+		s = s[2:]
+	}
+	return s
+}
@@ -0,0 +1,156 @@
+package forgewrap
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Phase is what forge was doing, as far as can be told from its output.
+type Phase string
+
+const (
+	PhaseStarting  Phase = "starting"
+	PhaseCompiling Phase = "compiling"
+	PhaseTesting   Phase = "testing"
+)
+
+var (
+	// compilingPattern matches forge starting to compile, e.g. "[⠒] Compiling 45 files with Solc 0.8.20".
+	compilingPattern = regexp.MustCompile(`\bCompiling\b`)
+	// compiledPattern matches forge finishing compilation.
+	compiledPattern = regexp.MustCompile(`Compiler run successful|No files changed, compilation skipped`)
+	// testingPattern matches forge reporting test results, e.g. "Ran 3 tests for test/Counter.t.sol:CounterTest".
+	testingPattern = regexp.MustCompile(`\bRan \d+ tests? for\b`)
+)
+
+// phaseTracker follows forge's phase through its output. It's shared by the
+// goroutines reading stdout and stderr.
+type phaseTracker struct {
+	mu    sync.Mutex
+	phase Phase
+	// test is true if forge is running tests, so finishing compilation means they've started.
+	test bool
+}
+
+// newPhaseTracker returns a tracker for forge run with args.
+func newPhaseTracker(args []string) *phaseTracker {
+	test := len(args) > 0 && (args[0] == "test" || args[0] == "snapshot")
+	return &phaseTracker{phase: PhaseStarting, test: test}
+}
+
+func (p *phaseTracker) line(line string) {
+	line = ansiPattern.ReplaceAllString(line, "")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case testingPattern.MatchString(line), p.test && compiledPattern.MatchString(line):
+		p.phase = PhaseTesting
+	case compilingPattern.MatchString(line) && p.phase == PhaseStarting:
+		p.phase = PhaseCompiling
+	}
+}
+
+func (p *phaseTracker) get() Phase {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.phase
+}
+
+// signalNames are the names of the signals forwarded to forge.
+var signalNames = map[os.Signal]string{
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGTERM: "SIGTERM",
+}
+
+// supervisor forwards SIGINT and SIGTERM to forge's process group, which is
+// separate from ours so that it can be stopped along with any solc processes
+// it started, and kills the group if forge runs past a timeout.
+type supervisor struct {
+	mu sync.Mutex
+	// stopped says why forge was stopped early, if it was.
+	stopped  string
+	timedOut bool
+	signals  chan os.Signal
+	timer    *time.Timer
+	done     chan struct{}
+}
+
+// supervise starts supervising the process group pgid. A zero timeout means none.
+func supervise(pgid int, timeout time.Duration) *supervisor {
+	s := &supervisor{signals: make(chan os.Signal, 1), done: make(chan struct{})}
+	signal.Notify(s.signals, syscall.SIGINT, syscall.SIGTERM)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		s.timer = time.NewTimer(timeout)
+		expired = s.timer.C
+	}
+	go func() {
+		for {
+			select {
+			case sig := <-s.signals:
+				s.stop("was stopped by "+signalNames[sig], false)
+				syscall.Kill(-pgid, sig.(syscall.Signal))
+			case <-expired:
+				s.stop(fmt.Sprintf("timed out after %s", timeout), true)
+				syscall.Kill(-pgid, syscall.SIGKILL)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// stop records the first reason forge was stopped.
+func (s *supervisor) stop(reason string, timedOut bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped == "" {
+		s.stopped, s.timedOut = reason, timedOut
+	}
+}
+
+// finish stops supervising and returns why forge was stopped early, if it was.
+func (s *supervisor) finish() (stopped string, timedOut bool) {
+	signal.Stop(s.signals)
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	close(s.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped, s.timedOut
+}
+
+// stoppedDiagnostic reports forge being stopped early, with what it was doing.
+func stoppedDiagnostic(reason string, timedOut bool, phase Phase) Diagnostic {
+	d := Diagnostic{
+		Severity: SeverityError,
+		Message:  fmt.Sprintf("forge %s while %s", reason, phase),
+	}
+	if !timedOut {
+		return d
+	}
+	hint := Hint{Summary: fmt.Sprintf("forge timed out while %s.", phase), Detail: d.Message}
+	switch phase {
+	case PhaseCompiling:
+		hint.Suggestions = []string{
+			"Increasing the timeout",
+			"Compiling without via_ir, or with fewer optimizer runs, outside release builds",
+		}
+	case PhaseTesting:
+		hint.Suggestions = []string{
+			"Increasing the timeout",
+			"Lowering the fuzz or invariant runs in foundry.toml",
+		}
+	default:
+		hint.Suggestions = []string{"Increasing the timeout"}
+	}
+	d.Hints = []Hint{hint}
+	return d
+}
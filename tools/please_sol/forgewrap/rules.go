@@ -0,0 +1,291 @@
+package forgewrap
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Rules configure the hints attached to diagnostics. They're loaded from a TOML
+// file such as:
+//
+//	[[hint]]
+//	pattern = "Stack too deep"
+//	summary = "Stack too deep error."
+//	suggestions = ["Enable via_ir in foundry.toml"]
+//
+//	[[import]]
+//	prefix = "@acme/contracts/"
+//	targets = ["//libs/acme:contracts"]
+//
+//	[[rename]]
+//	from = "security/Pausable.sol"
+//	to = "utils/Pausable.sol"
+//	version = "OpenZeppelin 5.0"
+type Rules struct {
+	// ReplaceDefaultHints discards the built-in hint rules rather than adding to them.
+	ReplaceDefaultHints bool `toml:"replace_default_hints"`
+	// ReplaceDefaultImports discards the built-in import rules rather than adding to them.
+	ReplaceDefaultImports bool `toml:"replace_default_imports"`
+	// ReplaceDefaultRenames discards the built-in rename rules rather than adding to them.
+	ReplaceDefaultRenames bool         `toml:"replace_default_renames"`
+	Hints                 []HintRule   `toml:"hint"`
+	Imports               []ImportRule `toml:"import"`
+	Renames               []RenameRule `toml:"rename"`
+}
+
+// HintRule attaches a hint to diagnostics whose message matches Pattern, whose
+// error code matches Code and whose type (e.g. "TypeError") matches Type. Any of
+// them may be empty, but not all.
+type HintRule struct {
+	Pattern     string   `toml:"pattern"`
+	Code        string   `toml:"code"`
+	Type        string   `toml:"type"`
+	Summary     string   `toml:"summary"`
+	Suggestions []string `toml:"suggestions"`
+	// Targets are build labels suggested as deps.
+	Targets []string `toml:"targets"`
+
+	pattern *regexp.Regexp
+}
+
+// ImportRule suggests deps for unresolved imports starting with Prefix.
+type ImportRule struct {
+	Prefix  string   `toml:"prefix"`
+	Targets []string `toml:"targets"`
+}
+
+// RenameRule records that a library moved or removed a file between versions.
+// From and To are paths within the library, matched against the end of an import path.
+type RenameRule struct {
+	From string `toml:"from"`
+	// To is empty if the file was removed.
+	To      string `toml:"to"`
+	Version string `toml:"version"`
+}
+
+// DefaultRules returns the built-in rules.
+func DefaultRules() *Rules {
+	rules := &Rules{
+		Hints: []HintRule{
+			{
+				Pattern:     "Source file requires different compiler version",
+				Summary:     "Compiler version mismatch.",
+				Suggestions: []string{"Try specifying solc_version in your sol_contract rule."},
+			},
+			{
+				Pattern: "Stack too deep",
+				Summary: "Stack too deep error.",
+				Suggestions: []string{
+					"Breaking up the function into smaller functions",
+					"Using structs to group variables",
+					"Enabling optimizer with higher runs",
+				},
+			},
+			{
+				// solc warns (5574) and forge build --sizes fails.
+				Pattern: `exceeds 24576 bytes|runtime size limit`,
+				Summary: "Contract exceeds the EIP-170 code size limit of 24576 bytes.",
+				Suggestions: []string{
+					"Lower the optimizer runs (OptimizerRuns in .plzconfig, or optimizer_runs in the rule's foundry_toml); fewer runs favour smaller code",
+					"Enable the optimizer if it's off (Optimize in .plzconfig, or optimizer in foundry_toml)",
+					"Move code into libraries with external functions, or split the contract",
+					"Add --via-ir to solc_flags, which often produces smaller code",
+					"If the contract is a test helper that's never deployed on chain, add it to the rule's skip list",
+				},
+			},
+			{
+				Pattern: `exceeds 49152 bytes|initcode size limit`,
+				Summary: "Contract exceeds the EIP-3860 initcode size limit of 49152 bytes.",
+				Suggestions: []string{
+					"Lower the optimizer runs (OptimizerRuns in .plzconfig, or optimizer_runs in the rule's foundry_toml)",
+					"Deploy large dependencies separately instead of creating them with new in the constructor",
+				},
+			},
+			{
+				Code:    "1878",
+				Summary: "Missing SPDX license identifier.",
+				Suggestions: []string{
+					"Add a comment such as // SPDX-License-Identifier: MIT to the top of the file",
+					"Use // SPDX-License-Identifier: UNLICENSED for code that isn't open source",
+				},
+			},
+			{
+				Code:    "7920",
+				Summary: "Identifier not found or not unique.",
+				Suggestions: []string{
+					"Check the name is declared, and imported if it's declared in another file; import {X} from \"...\" only brings in X",
+					"If two imports declare the same name, import one under an alias: import {X as Y} from \"...\"",
+					"If it comes from a dependency, check the dep is in the rule's deps and that its version still declares it",
+				},
+			},
+			{
+				Code:    "9582",
+				Summary: "Member not found or not visible.",
+				Suggestions: []string{
+					"If this started after upgrading a dependency, check its changelog for renamed or removed functions (e.g. OpenZeppelin 5.0 moved ECDSA.toEthSignedMessageHash to MessageHashUtils)",
+					"Check the member is public or external if it's used from another contract",
+					"For functions attached with using ... for, check the using directive applies to this type",
+				},
+			},
+			{
+				Type:    "YulException",
+				Summary: "via-IR code generation failed.",
+				Suggestions: []string{
+					"Enable the optimizer, which via-IR relies on to move variables off the stack (Optimize in .plzconfig, or optimizer in the rule's foundry_toml)",
+					"Reduce the local variables, parameters and return values of the function named in the error, e.g. by grouping them in a struct",
+					"Remove --via-ir from solc_flags to get an error with a source location",
+				},
+			},
+			{
+				Pattern: `Dynamic linking not supported|[Uu]nlinked librar|__\$[0-9a-fA-F]{34}\$__|[Ll]inking failed|find artifact for library`,
+				Summary: "Contract uses libraries that aren't linked.",
+				Suggestions: []string{
+					"Link deployed libraries with --libraries <file>:<Library>:<address> in solc_flags",
+					"Make the library's functions internal so they're inlined and don't need linking",
+					"Add the library's rule to deps so forge can compile and link it",
+				},
+			},
+		},
+		Imports: []ImportRule{
+			{Prefix: "@openzeppelin/contracts", Targets: []string{"//third_party/solidity:openzeppelin-contracts"}},
+			{Prefix: "@openzeppelin", Targets: []string{"//third_party/solidity:openzeppelin-contracts"}},
+			{Prefix: "openzeppelin-contracts", Targets: []string{"//third_party/solidity:openzeppelin-contracts"}},
+			{Prefix: "forge-std", Targets: []string{"//third_party/solidity:forge-std"}},
+			{Prefix: "solmate", Targets: []string{"//third_party/solidity:solmate"}},
+			{Prefix: "@solmate", Targets: []string{"//third_party/solidity:solmate"}},
+			{Prefix: "@rari-capital/solmate", Targets: []string{"//third_party/solidity:solmate"}},
+			{Prefix: "solady", Targets: []string{"//third_party/solidity:solady"}},
+		},
+		Renames: []RenameRule{
+			{From: "security/ReentrancyGuard.sol", To: "utils/ReentrancyGuard.sol", Version: "OpenZeppelin 5.0"},
+			{From: "security/Pausable.sol", To: "utils/Pausable.sol", Version: "OpenZeppelin 5.0"},
+			{From: "security/PullPayment.sol", Version: "OpenZeppelin 5.0"},
+			{From: "utils/Counters.sol", Version: "OpenZeppelin 5.0"},
+			{From: "token/ERC20/extensions/ERC20Snapshot.sol", Version: "OpenZeppelin 5.0"},
+			{From: "token/ERC20/extensions/draft-ERC20Permit.sol", To: "token/ERC20/extensions/ERC20Permit.sol", Version: "OpenZeppelin 5.0"},
+			{From: "token/ERC20/extensions/draft-IERC20Permit.sol", To: "token/ERC20/extensions/IERC20Permit.sol", Version: "OpenZeppelin 5.0"},
+			{From: "utils/cryptography/draft-EIP712.sol", To: "utils/cryptography/EIP712.sol", Version: "OpenZeppelin 5.0"},
+		},
+	}
+	if err := rules.compile(); err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+// LoadRules reads a rules file and combines it with the built-in rules. Its
+// rules take precedence over the built-in ones.
+func LoadRules(filename string) (*Rules, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	return ParseRules(data)
+}
+
+// ParseRules parses the contents of a rules file and combines it with the built-in rules.
+func ParseRules(data []byte) (*Rules, error) {
+	dec := toml.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	rules := &Rules{}
+	if err := dec.Decode(rules); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+	if err := rules.compile(); err != nil {
+		return nil, err
+	}
+
+	defaults := DefaultRules()
+	if !rules.ReplaceDefaultHints {
+		rules.Hints = append(rules.Hints, defaults.Hints...)
+	}
+	if !rules.ReplaceDefaultImports {
+		rules.Imports = append(rules.Imports, defaults.Imports...)
+	}
+	if !rules.ReplaceDefaultRenames {
+		rules.Renames = append(rules.Renames, defaults.Renames...)
+	}
+	return rules, nil
+}
+
+// compile validates the rules and compiles their patterns.
+func (r *Rules) compile() error {
+	for i := range r.Hints {
+		h := &r.Hints[i]
+		if h.Pattern == "" && h.Code == "" && h.Type == "" {
+			return fmt.Errorf("hint rule %d: one of pattern, code or type is required", i+1)
+		}
+		if h.Summary == "" {
+			return fmt.Errorf("hint rule %d: summary is required", i+1)
+		}
+		if h.Pattern != "" {
+			pattern, err := regexp.Compile(h.Pattern)
+			if err != nil {
+				return fmt.Errorf("hint rule %d: invalid pattern: %w", i+1, err)
+			}
+			h.pattern = pattern
+		}
+	}
+	for i, imp := range r.Imports {
+		if imp.Prefix == "" || len(imp.Targets) == 0 {
+			return fmt.Errorf("import rule %d: prefix and targets are required", i+1)
+		}
+	}
+	for i, rename := range r.Renames {
+		if rename.From == "" {
+			return fmt.Errorf("rename rule %d: from is required", i+1)
+		}
+	}
+	return nil
+}
+
+// matches returns true if the rule applies to d.
+func (h *HintRule) matches(d Diagnostic) bool {
+	return (h.Code == "" || h.Code == d.Code) && (h.Type == "" || h.Type == d.Type) &&
+		(h.pattern == nil || h.pattern.MatchString(d.Message))
+}
+
+// rename returns the path importPath moved to under the rule, and whether the
+// rule applies to it at all. The path is empty if the file was removed.
+func (r *RenameRule) rename(importPath string) (string, bool) {
+	var dir string
+	if importPath != r.From {
+		var ok bool
+		if dir, ok = strings.CutSuffix(importPath, "/"+r.From); !ok {
+			return "", false
+		}
+		dir += "/"
+	}
+	if r.To == "" {
+		return "", true
+	}
+	return dir + r.To, true
+}
+
+// in describes the version the rule's change happened in, for use in a sentence.
+func (r *RenameRule) in() string {
+	if r.Version == "" {
+		return ""
+	}
+	return " in " + r.Version
+}
+
+// importTargets returns the targets of the import rule with the longest prefix
+// of importPath. Earlier rules win ties.
+func (r *Rules) importTargets(importPath string) []string {
+	var best *ImportRule
+	for i, imp := range r.Imports {
+		if strings.HasPrefix(importPath, imp.Prefix) && (best == nil || len(imp.Prefix) > len(best.Prefix)) {
+			best = &r.Imports[i]
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.Targets
+}
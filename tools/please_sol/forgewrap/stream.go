@@ -0,0 +1,135 @@
+package forgewrap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// tailLines is how much of each stream is kept to report if forge fails
+// without printing anything that parses as a diagnostic.
+const tailLines = 50
+
+// Stream runs a forge command like Run, but copies its output to stdout and
+// stderr a line at a time as forge produces it, with paths rewritten. The output
+// is parsed for diagnostics as it goes rather than held in memory, so the
+// returned Result has only ExitCode, Diagnostics and the warning counts set;
+// pass its diagnostics to FormatHints and it to FormatWarnings for the text
+// summaries.
+//
+// forge runs in its own process group. SIGINT and SIGTERM are forwarded to it,
+// and it's killed if it runs past the timeout. Either way, a diagnostic reports
+// what forge was doing when it was stopped.
+func (w *Wrapper) Stream(forgePath string, args []string, stdout, stderr io.Writer) (*Result, error) {
+	cmd := exec.Command(forgePath, args...)
+	// forge gets its own process group so that signals and the timeout reach the
+	// solc processes it starts too.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run forge: %w", err)
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run forge: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run forge: %w", err)
+	}
+	supervisor := supervise(cmd.Process.Pid, w.timeout)
+
+	// Writes are serialised so that lines stay whole if both streams share a writer.
+	var mu sync.Mutex
+	phase := newPhaseTracker(args)
+	streams := []*lineStream{
+		{paths: w.paths, out: stdout, mu: &mu, phase: phase, parser: parser{phase: phase}},
+		{paths: w.paths, out: stderr, mu: &mu, phase: phase, parser: parser{phase: phase}},
+	}
+	var wg sync.WaitGroup
+	for i, pipe := range []io.Reader{stdoutPipe, stderrPipe} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			streams[i].copy(pipe)
+		}()
+	}
+	// The pipes must be drained before Wait closes them.
+	wg.Wait()
+	err = cmd.Wait()
+	stopped, timedOut := supervisor.finish()
+
+	result := &Result{}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+		// Like a shell, report death by a signal as 128 plus its number.
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			result.ExitCode = 128 + int(status.Signal())
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to run forge: %w", err)
+	}
+
+	// Depending on the version, forge reports compiler errors on either stream.
+	var tail []string
+	parsed := append(streams[0].parser.finish(), streams[1].parser.finish()...)
+	for _, s := range streams {
+		tail = append(tail, s.tail...)
+	}
+	// If forge was stopped, the truncated output isn't worth reporting as an error.
+	result.Diagnostics = w.annotate(parsed, strings.Join(tail, "\n"), result.ExitCode != 0 && stopped == "")
+	if stopped != "" {
+		result.Diagnostics = append(result.Diagnostics, stoppedDiagnostic(stopped, timedOut, phase.get()))
+	}
+	w.applyWarnings(result)
+
+	for _, s := range streams {
+		if s.err != nil {
+			return result, fmt.Errorf("failed to write forge output: %w", s.err)
+		}
+	}
+	return result, nil
+}
+
+// lineStream copies forge output a line at a time, parsing it as it goes.
+type lineStream struct {
+	paths  *PathMap
+	out    io.Writer
+	mu     *sync.Mutex
+	phase  *phaseTracker
+	parser parser
+	// tail is the last tailLines lines of output.
+	tail []string
+	// err is the first error writing to out. Output is still read after one so
+	// that forge doesn't block.
+	err error
+}
+
+func (s *lineStream) copy(r io.Reader) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if s.err == nil {
+				s.mu.Lock()
+				_, s.err = io.WriteString(s.out, s.paths.Rewrite(line))
+				s.mu.Unlock()
+			}
+			// Diagnostics are parsed from the original paths; annotate maps them.
+			line = strings.TrimSuffix(line, "\n")
+			s.parser.line(line)
+			s.phase.line(line)
+			if s.tail = append(s.tail, line); len(s.tail) > tailLines {
+				s.tail = s.tail[1:]
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package forgewrap
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"tools/please_sol/editdistance"
+	"tools/please_sol/importgraph"
+	"tools/please_sol/solparse"
+)
+
+// maxPathSuggestions is the most existing paths suggested for an unresolved import.
+const maxPathSuggestions = 3
+
+// suggestPaths suggests what an unresolved import may have meant: where a rename
+// rule says the file moved, and the existing files with the closest paths.
+func (w *Wrapper) suggestPaths(importPath string) []string {
+	files := w.candidateFiles(importPath)
+	exists := func(p string) bool {
+		i := sort.SearchStrings(files, p)
+		return i < len(files) && files[i] == p
+	}
+
+	var suggestions []string
+	suggested := map[string]bool{}
+	for _, rule := range w.rules.Renames {
+		moved, ok := rule.rename(importPath)
+		switch {
+		case !ok:
+		case moved == "":
+			suggestions = append(suggestions, fmt.Sprintf("%q was removed%s", importPath, rule.in()))
+		// Rules for one library can match another's paths, so only suggest files
+		// that exist if we know what does.
+		case len(files) == 0 || exists(moved):
+			suggestions = append(suggestions, fmt.Sprintf("Did you mean %q? It moved there%s", moved, rule.in()))
+			suggested[moved] = true
+		}
+	}
+
+	// If the file exists the import failed for another reason, like a missing dep.
+	if exists(importPath) {
+		return suggestions
+	}
+	for _, f := range closestPaths(importPath, files) {
+		if !suggested[f] {
+			suggestions = append(suggestions, fmt.Sprintf("Did you mean %q?", f))
+		}
+	}
+	return suggestions
+}
+
+// closestPaths returns the files closest to importPath by edit distance, up to
+// maxPathSuggestions of them. Files with the same name in another directory are
+// included however far apart the paths are, since libraries move files between
+// versions.
+func closestPaths(importPath string, files []string) []string {
+	type candidate struct {
+		path     string
+		distance int
+	}
+	name := path.Base(importPath)
+	limit := max(2, len(name)/3)
+	var candidates []candidate
+	for _, f := range files {
+		d := editdistance.Distance(importPath, f)
+		if d <= limit || path.Base(f) == name {
+			candidates = append(candidates, candidate{path: f, distance: d})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	var paths []string
+	for _, c := range candidates[:min(len(candidates), maxPathSuggestions)] {
+		paths = append(paths, c.path)
+	}
+	return paths
+}
+
+// candidateFiles returns the sorted import paths of the files that could be
+// imported with the same prefix as importPath: those under the target of the
+// longest remapping it matches, and those in the index. Workspace files in the
+// index are only candidates if no prefix matches.
+func (w *Wrapper) candidateFiles(importPath string) []string {
+	var files []string
+	if r, ok := w.remappingFor(importPath); ok {
+		files = append(files, w.remappedFiles(r)...)
+	}
+	if w.index != nil {
+		for _, e := range w.index.Entries {
+			if e.Prefix != "" && strings.HasPrefix(importPath, e.Prefix) {
+				for _, f := range e.Files {
+					files = append(files, e.Prefix+f)
+				}
+			}
+		}
+		if len(files) == 0 {
+			for _, e := range w.index.Entries {
+				if e.Prefix == "" {
+					files = append(files, e.Files...)
+				}
+			}
+		}
+	}
+	sort.Strings(files)
+	return slices.Compact(files)
+}
+
+// remappingFor returns the remapping with the longest prefix of importPath.
+func (w *Wrapper) remappingFor(importPath string) (importgraph.Remapping, bool) {
+	var best importgraph.Remapping
+	found := false
+	for _, line := range w.remappings {
+		r, err := importgraph.ParseRemapping(line)
+		if err != nil || r.Context != "" || !strings.HasPrefix(importPath, r.Prefix) {
+			continue
+		}
+		if !found || len(r.Prefix) > len(best.Prefix) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// remappedFiles returns the import paths of the Solidity files under a
+// remapping's target, which is relative to the directory forge runs in. The
+// walk is cached since one missing file often causes several errors.
+func (w *Wrapper) remappedFiles(r importgraph.Remapping) []string {
+	key := r.Prefix + "=" + r.Target
+	if files, ok := w.files[key]; ok {
+		return files
+	}
+	var files []string
+	target := filepath.FromSlash(r.Target)
+	sources, _ := solparse.FindSources([]string{target})
+	for _, src := range sources {
+		if rel, err := filepath.Rel(target, src); err == nil {
+			files = append(files, r.Prefix+filepath.ToSlash(rel))
+		}
+	}
+	if w.files == nil {
+		w.files = map[string][]string{}
+	}
+	w.files[key] = files
+	return files
+}
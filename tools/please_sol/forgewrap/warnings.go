@@ -0,0 +1,103 @@
+package forgewrap
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// WarningPolicy says what to do with compiler warnings.
+type WarningPolicy string
+
+const (
+	// WarningsIgnore leaves warnings out of the diagnostics.
+	WarningsIgnore WarningPolicy = "ignore"
+	// WarningsReport reports warnings without failing. It's the default.
+	WarningsReport WarningPolicy = "report"
+	// WarningsError fails the command if there are warnings that aren't allowed.
+	WarningsError WarningPolicy = "error"
+)
+
+// WarningsExitCode is the exit code when forge succeeded but WarningsError
+// failed the command, so that callers can tell the two apart.
+const WarningsExitCode = 3
+
+// SetWarnings sets the policy for compiler warnings and the solc warning codes
+// (e.g. "2072") that are allowed under WarningsError.
+func (w *Wrapper) SetWarnings(policy WarningPolicy, allowed []string) {
+	w.warnings = policy
+	w.allowedWarnings = allowed
+}
+
+// applyWarnings counts the warnings in the result's diagnostics and applies the
+// warning policy to them. Under WarningsError, warnings that aren't allowed
+// become errors and fail the command if forge succeeded.
+func (w *Wrapper) applyWarnings(result *Result) {
+	diagnostics := result.Diagnostics[:0]
+	for _, d := range result.Diagnostics {
+		if d.Severity != SeverityWarning {
+			diagnostics = append(diagnostics, d)
+			continue
+		}
+		result.Warnings++
+		if w.warnings == WarningsIgnore {
+			continue
+		}
+		if w.warnings == WarningsError && !w.warningAllowed(d) {
+			d.Severity, d.Type = SeverityError, "Warning"
+			result.DisallowedWarnings++
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	result.Diagnostics = diagnostics
+	if result.DisallowedWarnings > 0 && result.ExitCode == 0 {
+		result.ExitCode = WarningsExitCode
+	}
+}
+
+// warningAllowed returns true if d doesn't fail the command under WarningsError.
+// Warnings without a code come from forge rather than the compiler, and
+// warnings in files another target produced (i.e. sol_get code) can't be fixed
+// here, so both are always allowed.
+func (w *Wrapper) warningAllowed(d Diagnostic) bool {
+	return d.Code == "" || d.Target != "" || slices.Contains(w.allowedWarnings, d.Code)
+}
+
+// FormatWarnings summarises the warnings in a result, e.g. "3 compiler warnings
+// (2072 x2, 5667 x1)", and why the command failed if they failed it.
+func (w *Wrapper) FormatWarnings(result *Result) string {
+	if w.warnings == WarningsIgnore || result.Warnings == 0 {
+		return ""
+	}
+	counts := map[string]int{}
+	for _, d := range result.Diagnostics {
+		if d.Code != "" && (d.Severity == SeverityWarning || d.Type == "Warning") {
+			counts[d.Code]++
+		}
+	}
+	var codes []string
+	for code, n := range counts {
+		codes = append(codes, fmt.Sprintf("%s x%d", code, n))
+	}
+	sort.Strings(codes)
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "\n%d compiler warning%s", result.Warnings, plural(result.Warnings))
+	if len(codes) > 0 {
+		fmt.Fprintf(&summary, " (%s)", strings.Join(codes, ", "))
+	}
+	summary.WriteString("\n")
+	if result.DisallowedWarnings > 0 {
+		fmt.Fprintf(&summary, "%d warning%s not allowed; fix them or add their codes to the rule's allowed_warnings\n",
+			result.DisallowedWarnings, plural(result.DisallowedWarnings))
+	}
+	return summary.String()
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,189 @@
+package foundrytoml
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// ProfileEnvVar is the environment variable that selects the active profile.
+const ProfileEnvVar = "FOUNDRY_PROFILE"
+
+// envPrefixes are the prefixes of environment variables that override profile
+// keys, highest precedence first. DAPP_ is the legacy dapptools prefix.
+var envPrefixes = []string{"FOUNDRY_", "DAPP_"}
+
+// envOverride is a profile value supplied by an environment variable.
+type envOverride struct {
+	variable string
+	value    reflect.Value
+}
+
+// Source describes where a resolved profile value came from: "profile.<name>"
+// for foundry.toml, or "env <VAR>" for an environment variable.
+type Source struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// EnvMap converts a list of "KEY=value" strings, as returned by os.Environ, to a map.
+func EnvMap(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// WithEnv returns a copy of the config with Foundry's environment overrides
+// applied on top of the file: FOUNDRY_PROFILE selects the active profile, and
+// FOUNDRY_<KEY> (or the legacy DAPP_<KEY>) overrides <key> in every resolved
+// profile, e.g. FOUNDRY_OPTIMIZER_RUNS=1000 or FOUNDRY_FUZZ_RUNS=500 for
+// fuzz.runs. List values may be given either comma-separated or as a TOML array.
+func (c *Config) WithEnv(env map[string]string) (*Config, error) {
+	t := reflect.TypeOf(Profile{})
+	overrides := map[string]envOverride{}
+	for key, index := range profileFields() {
+		if envKey(key) != key {
+			// A flat key that shares its variable with a nested one, e.g. the legacy
+			// fuzz_runs and fuzz.runs both read FOUNDRY_FUZZ_RUNS; the nested key wins.
+			continue
+		}
+		names := []string{key}
+		for alias, aliased := range aliases {
+			if aliased == key {
+				// The alias is Foundry's canonical name, so e.g. FOUNDRY_SOLC beats
+				// FOUNDRY_SOLC_VERSION.
+				names = append([]string{alias}, names...)
+			}
+		}
+		var variables []string
+		for _, prefix := range envPrefixes {
+			for _, name := range names {
+				variables = append(variables, prefix+strings.ToUpper(strings.ReplaceAll(name, ".", "_")))
+			}
+		}
+		for _, variable := range variables {
+			raw, ok := env[variable]
+			if !ok {
+				continue
+			}
+			value, err := parseEnvValue(raw, t.FieldByIndex(index).Type)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", variable, err)
+			}
+			overrides[key] = envOverride{variable: variable, value: value}
+			break
+		}
+	}
+
+	withEnv := *c
+	withEnv.envProfile = env[ProfileEnvVar]
+	withEnv.envOverrides = overrides
+	return &withEnv, nil
+}
+
+// envKey returns the key that the environment variable for key applies to.
+func envKey(key string) string {
+	if nested := strings.Replace(key, "_", ".", 1); nested != key {
+		if _, ok := profileFields()[nested]; ok {
+			return nested
+		}
+	}
+	return key
+}
+
+// ActiveProfile returns the profile to use: name if it's given, otherwise the
+// profile selected by FOUNDRY_PROFILE, otherwise the default profile.
+func (c *Config) ActiveProfile(name string) string {
+	if name != "" {
+		return name
+	}
+	if c.envProfile != "" {
+		return c.envProfile
+	}
+	return DefaultProfile
+}
+
+// Sources returns every key set in the resolved profile along with where its
+// final value came from, sorted by key.
+func (c *Config) Sources(name string) []Source {
+	profile, sources := c.resolve(name)
+	if profile == nil {
+		return nil
+	}
+	fields := profileFields()
+	v := reflect.ValueOf(*profile)
+	result := make([]Source, 0, len(sources))
+	for key, source := range sources {
+		value, _ := fieldByIndex(v, fields[key], false)
+		result = append(result, Source{Key: key, Value: value.Interface(), Source: source})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// WriteSources writes sources as an aligned table of key, value and source.
+func WriteSources(w io.Writer, sources []Source) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, s := range sources {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, formatValue(s.Value), s.Source)
+	}
+	return tw.Flush()
+}
+
+// formatValue formats a profile value the way it would be given in the environment.
+func formatValue(value any) string {
+	if list, ok := value.([]string); ok {
+		return strings.Join(list, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// parseEnvValue parses an environment variable's value into the given field type.
+func parseEnvValue(raw string, t reflect.Type) (reflect.Value, error) {
+	raw = strings.TrimSpace(raw)
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(raw), nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("expected true or false, got %q", raw)
+		}
+		return reflect.ValueOf(b), nil
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.ReplaceAll(raw, "_", ""))
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("expected an integer, got %q", raw)
+		}
+		return reflect.ValueOf(n), nil
+	case reflect.Slice:
+		if strings.HasPrefix(raw, "[") {
+			var doc struct {
+				V []string `toml:"v"`
+			}
+			if err := toml.Unmarshal([]byte("v = "+raw), &doc); err != nil {
+				return reflect.Value{}, fmt.Errorf("invalid list %q: %w", raw, err)
+			}
+			return reflect.ValueOf(doc.V), nil
+		}
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return reflect.ValueOf(list), nil
+	}
+	return reflect.Value{}, fmt.Errorf("unsupported type %s", t)
+}
@@ -5,24 +5,54 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"reflect"
+	"strings"
 
 	"github.com/pelletier/go-toml/v2"
 )
 
+// DefaultProfile is the profile every other profile inherits from.
+const DefaultProfile = "default"
+
+// standalone is the setKeys entry for the top-level tables (e.g. [fmt]) that
+// apply to every profile.
+const standalone = ""
+
 // Config represents the parsed foundry.toml configuration.
 type Config struct {
-	Profile   map[string]Profile `toml:"profile" json:"profile,omitempty"`
-	Remapping []string           `toml:"remappings" json:"remappings,omitempty"`
+	Profile      map[string]Profile   `toml:"profile" json:"profile,omitempty"`
+	Remapping    []string             `toml:"remappings" json:"remappings,omitempty"`
+	RPCEndpoints map[string]string    `toml:"rpc_endpoints" json:"rpc_endpoints,omitempty"`
+	Etherscan    map[string]Etherscan `toml:"etherscan" json:"etherscan,omitempty"`
+
+	// Fmt is the top-level [fmt] table. It sits beneath each profile's own fmt table.
+	Fmt *Fmt `toml:"fmt" json:"fmt,omitempty"`
+
+	// setKeys records which keys each profile sets explicitly, so that resolution
+	// can tell an unset key apart from one set to its zero value. Keys in nested
+	// tables are recorded in dotted form, e.g. "fuzz.runs".
+	setKeys map[string]map[string]bool
+
+	// envProfile and envOverrides are set by WithEnv.
+	envProfile   string
+	envOverrides map[string]envOverride
 }
 
+// aliases maps alternative profile keys to the key Profile models them under.
+// Foundry's own name for the compiler setting is solc, with solc_version as an
+// alias; Profile keeps the longer name, which is what sol_contract uses.
+var aliases = map[string]string{"solc": "solc_version"}
+
 // Profile represents a foundry profile (e.g., default, ci, production).
 type Profile struct {
 	// Compiler settings
-	SolcVersion    string `toml:"solc_version" json:"solc_version,omitempty"`
-	EvmVersion     string `toml:"evm_version" json:"evm_version,omitempty"`
-	ViaIR          bool   `toml:"via_ir" json:"via_ir,omitempty"`
-	Optimizer      bool   `toml:"optimizer" json:"optimizer,omitempty"`
-	OptimizerRuns  int    `toml:"optimizer_runs" json:"optimizer_runs,omitempty"`
+	SolcVersion   string `toml:"solc_version" json:"solc_version,omitempty"`
+	EvmVersion    string `toml:"evm_version" json:"evm_version,omitempty"`
+	ViaIR         bool   `toml:"via_ir" json:"via_ir,omitempty"`
+	Optimizer     bool   `toml:"optimizer" json:"optimizer,omitempty"`
+	OptimizerRuns int    `toml:"optimizer_runs" json:"optimizer_runs,omitempty"`
+
+	OptimizerDetails *OptimizerDetails `toml:"optimizer_details" json:"optimizer_details,omitempty"`
 
 	// Path settings
 	Src       string   `toml:"src" json:"src,omitempty"`
@@ -36,12 +66,70 @@ type Profile struct {
 	Remappings []string `toml:"remappings" json:"remappings,omitempty"`
 
 	// Testing
-	FuzzRuns       int  `toml:"fuzz_runs" json:"fuzz_runs,omitempty"`
-	InvariantRuns  int  `toml:"invariant_runs" json:"invariant_runs,omitempty"`
-	Verbosity      int  `toml:"verbosity" json:"verbosity,omitempty"`
-	NoMatchTest    string `toml:"no_match_test" json:"no_match_test,omitempty"`
-	MatchTest      string `toml:"match_test" json:"match_test,omitempty"`
-	MatchContract  string `toml:"match_contract" json:"match_contract,omitempty"`
+	FuzzRuns      int    `toml:"fuzz_runs" json:"fuzz_runs,omitempty"`
+	InvariantRuns int    `toml:"invariant_runs" json:"invariant_runs,omitempty"`
+	Verbosity     int    `toml:"verbosity" json:"verbosity,omitempty"`
+	NoMatchTest   string `toml:"no_match_test" json:"no_match_test,omitempty"`
+	MatchTest     string `toml:"match_test" json:"match_test,omitempty"`
+	MatchContract string `toml:"match_contract" json:"match_contract,omitempty"`
+
+	Fuzz      *Fuzz      `toml:"fuzz" json:"fuzz,omitempty"`
+	Invariant *Invariant `toml:"invariant" json:"invariant,omitempty"`
+
+	// Formatting
+	Fmt *Fmt `toml:"fmt" json:"fmt,omitempty"`
+}
+
+// OptimizerDetails toggles individual solc optimizer steps.
+type OptimizerDetails struct {
+	Peephole          bool `toml:"peephole" json:"peephole,omitempty"`
+	Inliner           bool `toml:"inliner" json:"inliner,omitempty"`
+	JumpdestRemover   bool `toml:"jumpdest_remover" json:"jumpdest_remover,omitempty"`
+	OrderLiterals     bool `toml:"order_literals" json:"order_literals,omitempty"`
+	Deduplicate       bool `toml:"deduplicate" json:"deduplicate,omitempty"`
+	CSE               bool `toml:"cse" json:"cse,omitempty"`
+	ConstantOptimizer bool `toml:"constant_optimizer" json:"constant_optimizer,omitempty"`
+	Yul               bool `toml:"yul" json:"yul,omitempty"`
+}
+
+// Fuzz configures forge's fuzz tests.
+type Fuzz struct {
+	Runs           int    `toml:"runs" json:"runs,omitempty"`
+	Seed           string `toml:"seed" json:"seed,omitempty"`
+	MaxTestRejects int    `toml:"max_test_rejects" json:"max_test_rejects,omitempty"`
+}
+
+// Invariant configures forge's invariant tests.
+type Invariant struct {
+	Runs         int  `toml:"runs" json:"runs,omitempty"`
+	Depth        int  `toml:"depth" json:"depth,omitempty"`
+	FailOnRevert bool `toml:"fail_on_revert" json:"fail_on_revert,omitempty"`
+}
+
+// Fmt configures forge fmt.
+type Fmt struct {
+	LineLength                int      `toml:"line_length" json:"line_length,omitempty"`
+	TabWidth                  int      `toml:"tab_width" json:"tab_width,omitempty"`
+	BracketSpacing            bool     `toml:"bracket_spacing" json:"bracket_spacing,omitempty"`
+	IntTypes                  string   `toml:"int_types" json:"int_types,omitempty"`
+	MultilineFuncHeader       string   `toml:"multiline_func_header" json:"multiline_func_header,omitempty"`
+	QuoteStyle                string   `toml:"quote_style" json:"quote_style,omitempty"`
+	NumberUnderscore          string   `toml:"number_underscore" json:"number_underscore,omitempty"`
+	HexUnderscore             string   `toml:"hex_underscore" json:"hex_underscore,omitempty"`
+	SingleLineStatementBlocks string   `toml:"single_line_statement_blocks" json:"single_line_statement_blocks,omitempty"`
+	OverrideSpacing           bool     `toml:"override_spacing" json:"override_spacing,omitempty"`
+	WrapComments              bool     `toml:"wrap_comments" json:"wrap_comments,omitempty"`
+	ContractNewLines          bool     `toml:"contract_new_lines" json:"contract_new_lines,omitempty"`
+	SortImports               bool     `toml:"sort_imports" json:"sort_imports,omitempty"`
+	Ignore                    []string `toml:"ignore" json:"ignore,omitempty"`
+}
+
+// Etherscan is an entry in the [etherscan] table, used for contract verification.
+type Etherscan struct {
+	Key string `toml:"key" json:"key,omitempty"`
+	URL string `toml:"url" json:"url,omitempty"`
+	// Chain is either a chain ID or a chain name.
+	Chain any `toml:"chain" json:"chain,omitempty"`
 }
 
 // ParseFile parses a foundry.toml file.
@@ -59,23 +147,210 @@ func Parse(data []byte) (*Config, error) {
 	if err := toml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse foundry.toml: %w", err)
 	}
+
+	var raw struct {
+		Profile map[string]map[string]any `toml:"profile"`
+		Fmt     map[string]any            `toml:"fmt"`
+	}
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse foundry.toml: %w", err)
+	}
+	config.setKeys = map[string]map[string]bool{standalone: {}}
+	for name, keys := range raw.Profile {
+		config.setKeys[name] = map[string]bool{}
+		markSet(config.setKeys[name], "", keys)
+		for alias, key := range aliases {
+			if config.setKeys[name][alias] && config.setKeys[name][key] {
+				return nil, fmt.Errorf("profile.%s sets both %s and its alias %s", name, alias, key)
+			}
+		}
+		if solc, ok := keys["solc"].(string); ok {
+			profile := config.Profile[name]
+			profile.SolcVersion = solc
+			config.Profile[name] = profile
+		}
+		for alias, key := range aliases {
+			if config.setKeys[name][alias] {
+				config.setKeys[name][key] = true
+			}
+		}
+	}
+	markSet(config.setKeys[standalone], "fmt.", raw.Fmt)
 	return &config, nil
 }
 
-// GetProfile returns the specified profile, or the default profile if not found.
+// markSet records every key in a TOML table, recursing into nested tables.
+func markSet(set map[string]bool, prefix string, table map[string]any) {
+	for key, value := range table {
+		set[prefix+key] = true
+		if nested, ok := value.(map[string]any); ok {
+			markSet(set, prefix+key+".", nested)
+		}
+	}
+}
+
+// GetProfile returns the specified profile exactly as written, or the default
+// profile if not found. Most callers want ResolveProfile instead.
 func (c *Config) GetProfile(name string) *Profile {
 	if name == "" {
-		name = "default"
+		name = DefaultProfile
 	}
 	if profile, ok := c.Profile[name]; ok {
 		return &profile
 	}
-	if profile, ok := c.Profile["default"]; ok {
+	if profile, ok := c.Profile[DefaultProfile]; ok {
 		return &profile
 	}
 	return nil
 }
 
+// ResolveProfile returns the named profile layered on top of the default profile,
+// the way Foundry applies it: every key the named profile sets overrides the
+// default, and every key it leaves unset is inherited. A key explicitly set to a
+// zero value (e.g. optimizer = false) still overrides. Environment overrides
+// added by WithEnv are applied last.
+//
+// An empty name selects the active profile (see ActiveProfile). If the named
+// profile doesn't exist the default profile is returned, and if neither exists
+// (and there are no environment overrides) the result is nil.
+func (c *Config) ResolveProfile(name string) *Profile {
+	profile, _ := c.resolve(name)
+	return profile
+}
+
+// resolve resolves a profile and records the source of every key it sets.
+func (c *Config) resolve(name string) (*Profile, map[string]string) {
+	name = c.ActiveProfile(name)
+	base, hasDefault := c.Profile[DefaultProfile]
+	named, hasNamed := c.Profile[name]
+	if !hasDefault && !hasNamed && c.Fmt == nil && len(c.envOverrides) == 0 {
+		return nil, nil
+	}
+
+	var resolved Profile
+	sources := map[string]string{}
+	dst := reflect.ValueOf(&resolved).Elem()
+	overlay := func(profile, source string, p Profile) {
+		src := reflect.ValueOf(p)
+		for key, index := range profileFields() {
+			if value, ok := fieldByIndex(src, index, false); ok && c.isSet(profile, key, value) {
+				field, _ := fieldByIndex(dst, index, true)
+				field.Set(value)
+				sources[key] = source
+			}
+		}
+	}
+	if c.Fmt != nil {
+		overlay(standalone, "fmt", Profile{Fmt: c.Fmt})
+	}
+	if hasDefault {
+		overlay(DefaultProfile, "profile."+DefaultProfile, base)
+	}
+	if hasNamed && name != DefaultProfile {
+		overlay(name, "profile."+name, named)
+	}
+	for key, index := range profileFields() {
+		if o, ok := c.envOverrides[key]; ok {
+			field, _ := fieldByIndex(dst, index, true)
+			field.Set(o.value)
+			sources[key] = "env " + o.variable
+		}
+	}
+	return &resolved, sources
+}
+
+// ResolveProfiles returns a copy of the config with every profile resolved
+// against the default profile and the environment.
+func (c *Config) ResolveProfiles() *Config {
+	resolved := &Config{
+		Profile:      make(map[string]Profile, len(c.Profile)),
+		Remapping:    c.Remapping,
+		RPCEndpoints: c.RPCEndpoints,
+		Etherscan:    c.Etherscan,
+		Fmt:          c.Fmt,
+		setKeys:      c.setKeys,
+	}
+	for name := range c.Profile {
+		resolved.Profile[name] = *c.ResolveProfile(name)
+	}
+	return resolved
+}
+
+// MarkSet records that a profile sets the given keys explicitly, so that they're
+// inherited and marshalled even if zero (e.g. optimizer = false). Configs built in
+// code otherwise treat only non-zero values as set.
+func (c *Config) MarkSet(profile string, keys ...string) {
+	if c.setKeys == nil {
+		c.setKeys = map[string]map[string]bool{}
+		for name, p := range c.Profile {
+			c.setKeys[name] = map[string]bool{}
+			v := reflect.ValueOf(p)
+			for key, index := range profileFields() {
+				if value, ok := fieldByIndex(v, index, false); ok && !value.IsZero() {
+					c.setKeys[name][key] = true
+				}
+			}
+		}
+	}
+	if c.setKeys[profile] == nil {
+		c.setKeys[profile] = map[string]bool{}
+	}
+	for _, key := range keys {
+		c.setKeys[profile][key] = true
+	}
+}
+
+// isSet returns true if the profile sets the given key. Configs that weren't
+// created by Parse have no record of which keys were set, so any non-zero value
+// is treated as set.
+func (c *Config) isSet(profile, key string, value reflect.Value) bool {
+	if c.setKeys == nil {
+		return !value.IsZero()
+	}
+	return c.setKeys[profile][key]
+}
+
+// profileFields maps each Profile TOML key to its struct field index. Nested
+// tables are flattened into dotted keys, e.g. "fuzz.runs".
+func profileFields() map[string][]int {
+	fields := map[string][]int{}
+	addFields(fields, reflect.TypeOf(Profile{}), "", nil)
+	return fields
+}
+
+func addFields(fields map[string][]int, t reflect.Type, prefix string, parent []int) {
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		index := append(append([]int(nil), parent...), i)
+		if ft := t.Field(i).Type; ft.Kind() == reflect.Pointer && ft.Elem().Kind() == reflect.Struct {
+			addFields(fields, ft.Elem(), prefix+key+".", index)
+		} else {
+			fields[prefix+key] = index
+		}
+	}
+}
+
+// fieldByIndex returns the nested field at index. Nil pointers on the way are
+// allocated if alloc is true; otherwise the field is reported missing.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
 // ToJSON converts the config to JSON for use in build rules.
 func (c *Config) ToJSON() ([]byte, error) {
 	return json.MarshalIndent(c, "", "  ")
@@ -83,7 +358,7 @@ func (c *Config) ToJSON() ([]byte, error) {
 
 // GetSolcVersion returns the solc version from the specified profile.
 func (c *Config) GetSolcVersion(profile string) string {
-	p := c.GetProfile(profile)
+	p := c.ResolveProfile(profile)
 	if p == nil {
 		return ""
 	}
@@ -98,7 +373,7 @@ func (c *Config) GetRemappings(profile string) []string {
 	remappings = append(remappings, c.Remapping...)
 
 	// Profile-specific remappings
-	p := c.GetProfile(profile)
+	p := c.ResolveProfile(profile)
 	if p != nil {
 		remappings = append(remappings, p.Remappings...)
 	}
@@ -108,7 +383,7 @@ func (c *Config) GetRemappings(profile string) []string {
 
 // GetOptimizerSettings returns optimizer settings from the specified profile.
 func (c *Config) GetOptimizerSettings(profile string) (enabled bool, runs int) {
-	p := c.GetProfile(profile)
+	p := c.ResolveProfile(profile)
 	if p == nil {
 		return false, 0
 	}
@@ -117,7 +392,7 @@ func (c *Config) GetOptimizerSettings(profile string) (enabled bool, runs int) {
 
 // GetEvmVersion returns the EVM version from the specified profile.
 func (c *Config) GetEvmVersion(profile string) string {
-	p := c.GetProfile(profile)
+	p := c.ResolveProfile(profile)
 	if p == nil {
 		return ""
 	}
@@ -2,7 +2,10 @@ package foundrytoml
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+
+	"github.com/pelletier/go-toml/v2"
 )
 
 func TestParse_BasicConfig(t *testing.T) {
@@ -293,3 +296,657 @@ via_ir = true
 		t.Error("production: expected via_ir to be true")
 	}
 }
+
+func TestResolveProfile_InheritsDefault(t *testing.T) {
+	input := `
+[profile.default]
+solc_version = "0.8.23"
+optimizer = true
+optimizer_runs = 200
+remappings = ["forge-std/=lib/forge-std/src/"]
+
+[profile.ci]
+fuzz_runs = 10000
+`
+	config, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	ci := config.ResolveProfile("ci")
+	if ci == nil {
+		t.Fatal("expected ci profile")
+	}
+	if ci.FuzzRuns != 10000 {
+		t.Errorf("expected 10000 fuzz runs, got %d", ci.FuzzRuns)
+	}
+	if ci.SolcVersion != "0.8.23" {
+		t.Errorf("expected solc 0.8.23 inherited from default, got %q", ci.SolcVersion)
+	}
+
+	enabled, runs := config.GetOptimizerSettings("ci")
+	if !enabled || runs != 200 {
+		t.Errorf("expected optimizer inherited from default (true, 200), got (%v, %d)", enabled, runs)
+	}
+
+	if remappings := config.GetRemappings("ci"); len(remappings) != 1 {
+		t.Errorf("expected remappings inherited from default, got %v", remappings)
+	}
+
+	// The raw profile is unchanged
+	if raw := config.GetProfile("ci"); raw.Optimizer {
+		t.Error("expected GetProfile to return the profile as written")
+	}
+}
+
+func TestResolveProfile_ExplicitZeroOverrides(t *testing.T) {
+	input := `
+[profile.default]
+optimizer = true
+optimizer_runs = 200
+via_ir = true
+
+[profile.debug]
+optimizer = false
+optimizer_runs = 0
+`
+	config, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	enabled, runs := config.GetOptimizerSettings("debug")
+	if enabled || runs != 0 {
+		t.Errorf("expected explicit optimizer = false to override default, got (%v, %d)", enabled, runs)
+	}
+	if !config.ResolveProfile("debug").ViaIR {
+		t.Error("expected via_ir inherited from default")
+	}
+}
+
+func TestResolveProfile_Fallbacks(t *testing.T) {
+	config, err := Parse([]byte(`
+[profile.default]
+solc_version = "0.8.20"
+
+[profile.only]
+evm_version = "paris"
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if p := config.ResolveProfile("missing"); p == nil || p.SolcVersion != "0.8.20" {
+		t.Errorf("expected missing profile to fall back to default, got %+v", p)
+	}
+	if p := config.ResolveProfile(""); p == nil || p.SolcVersion != "0.8.20" {
+		t.Errorf("expected empty name to resolve default, got %+v", p)
+	}
+
+	noDefault, err := Parse([]byte(`
+[profile.only]
+evm_version = "paris"
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if p := noDefault.ResolveProfile("only"); p == nil || p.EvmVersion != "paris" {
+		t.Errorf("expected profile without default to resolve as-is, got %+v", p)
+	}
+	if p := noDefault.ResolveProfile("missing"); p != nil {
+		t.Errorf("expected nil for missing profile with no default, got %+v", p)
+	}
+}
+
+func TestResolveProfiles(t *testing.T) {
+	config, err := Parse([]byte(`
+[profile.default]
+solc_version = "0.8.20"
+
+[profile.ci]
+fuzz_runs = 500
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	resolved := config.ResolveProfiles()
+	if resolved.Profile["ci"].SolcVersion != "0.8.20" {
+		t.Errorf("expected resolved ci profile to inherit solc_version, got %+v", resolved.Profile["ci"])
+	}
+	if config.Profile["ci"].SolcVersion != "" {
+		t.Error("expected original config to be unchanged")
+	}
+}
+
+func TestWithEnv_Overrides(t *testing.T) {
+	input := `
+[profile.default]
+solc_version = "0.8.20"
+optimizer = true
+optimizer_runs = 200
+libs = ["lib"]
+
+[profile.ci]
+optimizer_runs = 10000
+`
+	config, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	config, err = config.WithEnv(map[string]string{
+		"FOUNDRY_PROFILE":        "ci",
+		"FOUNDRY_SOLC_VERSION":   "0.8.24",
+		"DAPP_SOLC_VERSION":      "0.8.19",
+		"DAPP_VIA_IR":            "true",
+		"FOUNDRY_LIBS":           "lib, node_modules",
+		"FOUNDRY_REMAPPINGS":     `["a/=lib/a/", "b/=lib/b/"]`,
+		"FOUNDRY_UNRELATED_FLAG": "x",
+	})
+	if err != nil {
+		t.Fatalf("WithEnv failed: %v", err)
+	}
+
+	if config.ActiveProfile("") != "ci" {
+		t.Errorf("expected FOUNDRY_PROFILE to select ci, got %q", config.ActiveProfile(""))
+	}
+	if config.ActiveProfile("default") != "default" {
+		t.Errorf("expected explicit profile to win, got %q", config.ActiveProfile("default"))
+	}
+
+	p := config.ResolveProfile("")
+	if p.SolcVersion != "0.8.24" {
+		t.Errorf("expected FOUNDRY_ to beat DAPP_, got solc_version %q", p.SolcVersion)
+	}
+	if !p.ViaIR {
+		t.Error("expected DAPP_VIA_IR to apply")
+	}
+	if p.OptimizerRuns != 10000 {
+		t.Errorf("expected optimizer_runs 10000 from ci, got %d", p.OptimizerRuns)
+	}
+	if len(p.Libs) != 2 || p.Libs[1] != "node_modules" {
+		t.Errorf("expected comma-separated libs, got %v", p.Libs)
+	}
+	if len(p.Remappings) != 2 || p.Remappings[1] != "b/=lib/b/" {
+		t.Errorf("expected array remappings, got %v", p.Remappings)
+	}
+
+	// Environment overrides apply to explicitly named profiles too.
+	if v := config.GetSolcVersion("default"); v != "0.8.24" {
+		t.Errorf("expected env override on default profile, got %q", v)
+	}
+}
+
+func TestWithEnv_InvalidValue(t *testing.T) {
+	config, err := Parse([]byte("[profile.default]\noptimizer_runs = 200\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	for _, env := range []map[string]string{
+		{"FOUNDRY_OPTIMIZER_RUNS": "lots"},
+		{"FOUNDRY_OPTIMIZER": "maybe"},
+		{"FOUNDRY_LIBS": "[unterminated"},
+	} {
+		if _, err := config.WithEnv(env); err == nil {
+			t.Errorf("expected error for %v", env)
+		}
+	}
+}
+
+func TestWithEnv_NoProfiles(t *testing.T) {
+	config, err := Parse([]byte(""))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if config.ResolveProfile("") != nil {
+		t.Error("expected nil profile without config or env")
+	}
+	config, err = config.WithEnv(map[string]string{"FOUNDRY_EVM_VERSION": "cancun"})
+	if err != nil {
+		t.Fatalf("WithEnv failed: %v", err)
+	}
+	if v := config.GetEvmVersion(""); v != "cancun" {
+		t.Errorf("expected evm_version from env, got %q", v)
+	}
+}
+
+func TestSources(t *testing.T) {
+	input := `
+[profile.default]
+solc_version = "0.8.20"
+optimizer = true
+
+[profile.ci]
+optimizer = false
+`
+	config, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	config, err = config.WithEnv(map[string]string{"FOUNDRY_OPTIMIZER_RUNS": "1_000"})
+	if err != nil {
+		t.Fatalf("WithEnv failed: %v", err)
+	}
+
+	want := []Source{
+		{Key: "optimizer", Value: false, Source: "profile.ci"},
+		{Key: "optimizer_runs", Value: 1000, Source: "env FOUNDRY_OPTIMIZER_RUNS"},
+		{Key: "solc_version", Value: "0.8.20", Source: "profile.default"},
+	}
+	got := config.Sources("ci")
+	if len(got) != len(want) {
+		t.Fatalf("expected sources %+v, got %+v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("source %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	var b strings.Builder
+	if err := WriteSources(&b, got); err != nil {
+		t.Fatalf("WriteSources failed: %v", err)
+	}
+	if !strings.Contains(b.String(), "optimizer_runs  1000    env FOUNDRY_OPTIMIZER_RUNS\n") {
+		t.Errorf("unexpected table:\n%s", b.String())
+	}
+}
+
+func TestEnvMap(t *testing.T) {
+	env := EnvMap([]string{"FOUNDRY_PROFILE=ci", "X=a=b", "BROKEN"})
+	if env["FOUNDRY_PROFILE"] != "ci" || env["X"] != "a=b" || len(env) != 2 {
+		t.Errorf("unexpected env map: %v", env)
+	}
+}
+
+func TestParse_NestedTables(t *testing.T) {
+	input := `
+[profile.default]
+optimizer = true
+
+[profile.default.optimizer_details]
+yul = true
+cse = true
+
+[profile.default.fuzz]
+runs = 256
+seed = "0x3e8"
+
+[profile.default.invariant]
+runs = 64
+depth = 15
+fail_on_revert = true
+
+[profile.ci.fuzz]
+runs = 10000
+
+[rpc_endpoints]
+mainnet = "${MAINNET_RPC_URL}"
+
+[etherscan]
+mainnet = { key = "${ETHERSCAN_KEY}", chain = 1 }
+sepolia = { key = "k", chain = "sepolia", url = "https://api-sepolia.etherscan.io/api" }
+
+[fmt]
+line_length = 100
+sort_imports = true
+
+[profile.ci.fmt]
+line_length = 120
+`
+	config, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if config.RPCEndpoints["mainnet"] != "${MAINNET_RPC_URL}" {
+		t.Errorf("unexpected rpc_endpoints: %v", config.RPCEndpoints)
+	}
+	if config.Etherscan["sepolia"].Chain != "sepolia" || config.Etherscan["mainnet"].Key != "${ETHERSCAN_KEY}" {
+		t.Errorf("unexpected etherscan: %+v", config.Etherscan)
+	}
+
+	ci := config.ResolveProfile("ci")
+	if ci.Fuzz.Runs != 10000 || ci.Fuzz.Seed != "0x3e8" {
+		t.Errorf("expected ci fuzz to merge with default, got %+v", ci.Fuzz)
+	}
+	if ci.Invariant == nil || ci.Invariant.Depth != 15 || !ci.Invariant.FailOnRevert {
+		t.Errorf("expected invariant inherited from default, got %+v", ci.Invariant)
+	}
+	if ci.OptimizerDetails == nil || !ci.OptimizerDetails.Yul || ci.OptimizerDetails.Inliner {
+		t.Errorf("unexpected optimizer_details: %+v", ci.OptimizerDetails)
+	}
+	if ci.Fmt == nil || ci.Fmt.LineLength != 120 || !ci.Fmt.SortImports {
+		t.Errorf("expected ci fmt layered over [fmt], got %+v", ci.Fmt)
+	}
+
+	// Resolving must not write through to the parsed profiles.
+	if config.Profile["ci"].Fuzz.Seed != "" {
+		t.Error("resolution modified the parsed ci profile")
+	}
+
+	jsonBytes, err := config.ResolveProfiles().ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	var parsed struct {
+		Profile map[string]struct {
+			Fuzz struct {
+				Runs int `json:"runs"`
+			} `json:"fuzz"`
+		} `json:"profile"`
+		RPCEndpoints map[string]string `json:"rpc_endpoints"`
+		Fmt          map[string]any    `json:"fmt"`
+	}
+	if err := json.Unmarshal(jsonBytes, &parsed); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if parsed.Profile["ci"].Fuzz.Runs != 10000 || parsed.RPCEndpoints["mainnet"] == "" || parsed.Fmt["line_length"] != float64(100) {
+		t.Errorf("nested tables missing from JSON:\n%s", jsonBytes)
+	}
+}
+
+func TestTestEnv(t *testing.T) {
+	input := `
+[profile.default]
+fuzz_runs = 100
+invariant_runs = 32
+
+[profile.default.fuzz]
+runs = 256
+seed = "0x3e8"
+
+[profile.default.invariant]
+fail_on_revert = false
+`
+	config, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	config, err = config.WithEnv(map[string]string{"FOUNDRY_FUZZ_MAX_TEST_REJECTS": "1000"})
+	if err != nil {
+		t.Fatalf("WithEnv failed: %v", err)
+	}
+
+	want := []string{
+		"export FOUNDRY_FUZZ_MAX_TEST_REJECTS=1000",
+		"export FOUNDRY_FUZZ_RUNS=256",
+		"export FOUNDRY_FUZZ_SEED=0x3e8",
+		"export FOUNDRY_INVARIANT_FAIL_ON_REVERT=false",
+		"export FOUNDRY_INVARIANT_RUNS=32",
+	}
+	got := config.TestEnv("")
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestFmtTOML(t *testing.T) {
+	input := `
+[fmt]
+line_length = 100
+quote_style = "double"
+
+[profile.default.fmt]
+line_length = 120
+`
+	config, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	data, err := config.FmtTOML("")
+	if err != nil {
+		t.Fatalf("FmtTOML failed: %v", err)
+	}
+
+	var parsed Config
+	if err := toml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("invalid TOML %q: %v", data, err)
+	}
+	if parsed.Fmt == nil || parsed.Fmt.LineLength != 120 || parsed.Fmt.QuoteStyle != "double" {
+		t.Errorf("unexpected fmt table:\n%s", data)
+	}
+	if strings.Contains(string(data), "tab_width") {
+		t.Errorf("expected unset keys to be omitted:\n%s", data)
+	}
+}
+
+func TestCheck_UnknownKeys(t *testing.T) {
+	input := `remappings = []
+
+[profile.default]
+solc-version = "0.8.20"
+optimiser_runs = 200
+ffi = true
+
+[profile.ci.fuzz]
+rnus = 1000
+dictionary_weight = 40
+
+[fmt]
+line_lenght = 100
+
+[etherscan]
+mainnet = { key = "k", chian = 1 }
+
+[profile.default.frobnicate]
+x = 1
+`
+	unknown, err := Check([]byte(input))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	want := []UnknownKey{
+		{Key: "profile.default.solc-version", Line: 4, Column: 1, Suggestion: "solc_version"},
+		{Key: "profile.default.optimiser_runs", Line: 5, Column: 1, Suggestion: "optimizer_runs"},
+		{Key: "profile.ci.fuzz.rnus", Line: 9, Column: 1, Suggestion: "runs"},
+		{Key: "fmt.line_lenght", Line: 13, Column: 1, Suggestion: "line_length"},
+		{Key: "etherscan.mainnet.chian", Line: 16, Column: 24, Suggestion: "chain"},
+		{Key: "profile.default.frobnicate", Line: 18, Column: 2},
+	}
+	if len(unknown) != len(want) {
+		t.Fatalf("expected %d unknown keys, got %+v", len(want), unknown)
+	}
+	for i := range want {
+		if unknown[i] != want[i] {
+			t.Errorf("unknown key %d: expected %+v, got %+v", i, want[i], unknown[i])
+		}
+	}
+	if s := unknown[1].String(); s != "5:1: unknown key profile.default.optimiser_runs (did you mean optimizer_runs?)" {
+		t.Errorf("unexpected message: %s", s)
+	}
+}
+
+func TestCheck_Clean(t *testing.T) {
+	unknown, err := Check([]byte("[profile.default]\nsolc_version = \"0.8.20\"\n"))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(unknown) != 0 {
+		t.Errorf("expected no unknown keys, got %+v", unknown)
+	}
+	if _, err := Check([]byte("[profile.default\n")); err == nil {
+		t.Error("expected error for invalid TOML")
+	}
+}
+
+func TestSolcAlias(t *testing.T) {
+	input := []byte(`
+[profile.default]
+solc = "0.8.20"
+optimizer = true
+
+[profile.ci]
+solc = "0.8.24"
+`)
+	unknown, err := Check(input)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(unknown) != 0 {
+		t.Errorf("expected solc to be accepted in strict mode, got %+v", unknown)
+	}
+
+	config, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if v := config.GetSolcVersion("default"); v != "0.8.20" {
+		t.Errorf("expected solc 0.8.20, got %q", v)
+	}
+	if v := config.ResolveProfile("ci").SolcVersion; v != "0.8.24" {
+		t.Errorf("expected ci to override solc with 0.8.24, got %q", v)
+	}
+	if flags := strings.Join(config.ForgeFlags("ci", false, "."), " "); flags != "--optimize" {
+		t.Errorf("expected ci to inherit optimizer, got %v", flags)
+	}
+
+	config, err = config.WithEnv(map[string]string{
+		"FOUNDRY_SOLC":         "0.8.26",
+		"FOUNDRY_SOLC_VERSION": "0.8.25",
+	})
+	if err != nil {
+		t.Fatalf("WithEnv failed: %v", err)
+	}
+	if v := config.GetSolcVersion("default"); v != "0.8.26" {
+		t.Errorf("expected FOUNDRY_SOLC to beat FOUNDRY_SOLC_VERSION, got %q", v)
+	}
+
+	_, err = Parse([]byte("[profile.default]\nsolc = \"0.8.20\"\nsolc_version = \"0.8.24\"\n"))
+	if err == nil || !strings.Contains(err.Error(), "both solc and its alias solc_version") {
+		t.Errorf("expected an error for both solc and solc_version, got %v", err)
+	}
+}
+
+func TestMarshal_RoundTrip(t *testing.T) {
+	input := `
+remappings = ["forge-std/=lib/forge-std/src/"]
+
+[rpc_endpoints]
+mainnet = "https://eth.example.com"
+
+[etherscan]
+mainnet = { key = "k", chain = 1 }
+
+[fmt]
+line_length = 100
+
+[profile.default]
+solc_version = "0.8.20"
+optimizer = true
+optimizer_runs = 200
+
+[profile.default.fuzz]
+runs = 256
+
+[profile.ci]
+optimizer = false
+`
+	config, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	data, err := config.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if strings.Contains(string(data), "via_ir") {
+		t.Errorf("expected unset keys to be omitted:\n%s", data)
+	}
+
+	reparsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("failed to parse marshalled config %q: %v", data, err)
+	}
+	before, _ := config.ResolveProfiles().ToJSON()
+	after, _ := reparsed.ResolveProfiles().ToJSON()
+	if string(before) != string(after) {
+		t.Errorf("round trip changed the config:\n%s\nvs\n%s", before, after)
+	}
+	// An explicit false must survive the round trip so ci still overrides default.
+	if _, runs := reparsed.GetOptimizerSettings("ci"); runs != 200 {
+		t.Errorf("expected ci to inherit optimizer_runs, got %d", runs)
+	}
+	if enabled, _ := reparsed.GetOptimizerSettings("ci"); enabled {
+		t.Error("expected ci optimizer = false to survive the round trip")
+	}
+}
+
+func TestMarshal_Constructed(t *testing.T) {
+	config := &Config{
+		Profile: map[string]Profile{
+			DefaultProfile: {SolcVersion: "0.8.24", Optimizer: true, OptimizerRuns: 100},
+		},
+	}
+	data, err := config.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := "[profile]\n[profile.default]\noptimizer = true\noptimizer_runs = 100\nsolc_version = '0.8.24'\n"
+	if string(data) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, data)
+	}
+}
+
+func TestMarkSet(t *testing.T) {
+	config := &Config{Profile: map[string]Profile{
+		DefaultProfile: {Optimizer: true, OptimizerRuns: 200},
+		"ci":           {},
+	}}
+	config.MarkSet("ci", "optimizer")
+
+	enabled, runs := config.GetOptimizerSettings("ci")
+	if enabled || runs != 200 {
+		t.Errorf("expected ci to disable the optimizer and inherit runs, got %v %d", enabled, runs)
+	}
+}
+
+func TestForgeFlags(t *testing.T) {
+	input := `
+remappings = ["forge-std/=lib/forge-std/src/"]
+
+[profile.default]
+optimizer = true
+optimizer_runs = 200
+evm_version = "paris"
+fuzz_runs = 100
+
+[profile.ci]
+via_ir = true
+remappings = ["weird/=/abs/path with space/"]
+
+[profile.ci.fuzz]
+runs = 5000
+seed = "0x1"
+`
+	config, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	build := ShellJoin(config.ForgeFlags("default", false, "contracts"))
+	wantBuild := "--optimize --optimizer-runs 200 --evm-version paris --remappings forge-std/=contracts/lib/forge-std/src/"
+	if build != wantBuild {
+		t.Errorf("expected build flags:\n%s\ngot:\n%s", wantBuild, build)
+	}
+
+	test := ShellJoin(config.ForgeFlags("ci", true, "."))
+	wantTest := "--optimize --optimizer-runs 200 --via-ir --evm-version paris --fuzz-runs 5000 --fuzz-seed 0x1 " +
+		"--remappings forge-std/=lib/forge-std/src/ --remappings 'weird/=/abs/path with space/'"
+	if test != wantTest {
+		t.Errorf("expected test flags:\n%s\ngot:\n%s", wantTest, test)
+	}
+
+	if flags := config.ForgeFlags("default", true, ""); !strings.Contains(ShellJoin(flags), "--fuzz-runs 100") {
+		t.Errorf("expected legacy fuzz_runs to be used, got %v", flags)
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	got := ShellJoin([]string{"plain", "", "it's", "a b", "$HOME"})
+	want := `plain '' 'it'\''s' 'a b' '$HOME'`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
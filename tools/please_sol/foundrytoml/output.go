@@ -0,0 +1,194 @@
+package foundrytoml
+
+import (
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// TestEnv returns the environment variables that carry the profile's fuzz and
+// invariant settings to forge test, as "export NAME=value" lines sorted by name.
+// Only keys the profile sets are included, so forge's own defaults still apply
+// to the rest.
+func (c *Config) TestEnv(name string) []string {
+	profile, sources := c.resolve(name)
+	if profile == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(sources))
+	for key := range sources {
+		if strings.HasPrefix(key, "fuzz") || strings.HasPrefix(key, "invariant") {
+			keys = append(keys, key)
+		}
+	}
+	// Nested keys sort before their legacy flat equivalents ("fuzz.runs" < "fuzz_runs"),
+	// so they win when both are set.
+	sort.Strings(keys)
+
+	fields := profileFields()
+	v := reflect.ValueOf(*profile)
+	seen := map[string]bool{}
+	var lines []string
+	for _, key := range keys {
+		variable := "FOUNDRY_" + strings.ToUpper(strings.ReplaceAll(envKey(key), ".", "_"))
+		if seen[variable] {
+			continue
+		}
+		seen[variable] = true
+		value, _ := fieldByIndex(v, fields[key], false)
+		lines = append(lines, "export "+variable+"="+shellQuote(formatValue(value.Interface())))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// FmtTOML returns a foundry.toml containing just the profile's resolved [fmt]
+// table, for forge fmt to read.
+func (c *Config) FmtTOML(name string) ([]byte, error) {
+	table := map[string]any{}
+	profile, sources := c.resolve(name)
+	if profile != nil {
+		fields := profileFields()
+		v := reflect.ValueOf(*profile)
+		for key := range sources {
+			if fmtKey, ok := strings.CutPrefix(key, "fmt."); ok {
+				value, _ := fieldByIndex(v, fields[key], false)
+				table[fmtKey] = value.Interface()
+			}
+		}
+	}
+	return toml.Marshal(map[string]any{"fmt": table})
+}
+
+// Marshal encodes the config as foundry.toml. Only keys that are set are written,
+// so the output parses back to the same config.
+func (c *Config) Marshal() ([]byte, error) {
+	doc := map[string]any{}
+	if len(c.Remapping) > 0 {
+		doc["remappings"] = c.Remapping
+	}
+	if len(c.RPCEndpoints) > 0 {
+		doc["rpc_endpoints"] = c.RPCEndpoints
+	}
+	if len(c.Etherscan) > 0 {
+		etherscan := map[string]any{}
+		for name, e := range c.Etherscan {
+			etherscan[name] = encodeTable(reflect.ValueOf(e), "", func(_ string, v reflect.Value) bool { return !v.IsZero() })
+		}
+		doc["etherscan"] = etherscan
+	}
+	if c.Fmt != nil {
+		doc["fmt"] = encodeTable(reflect.ValueOf(*c.Fmt), "fmt.", func(key string, v reflect.Value) bool {
+			return c.isSet(standalone, key, v)
+		})
+	}
+	if len(c.Profile) > 0 {
+		profiles := map[string]any{}
+		for name, p := range c.Profile {
+			profiles[name] = encodeTable(reflect.ValueOf(p), "", func(key string, v reflect.Value) bool {
+				return c.isSet(name, key, v)
+			})
+		}
+		doc["profile"] = profiles
+	}
+	return toml.Marshal(doc)
+}
+
+// encodeTable converts a struct to a TOML table of the keys isSet accepts.
+// Nested tables are included if any of their keys are set.
+func encodeTable(v reflect.Value, prefix string, isSet func(key string, value reflect.Value) bool) map[string]any {
+	table := map[string]any{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Struct {
+			if !field.IsNil() {
+				if nested := encodeTable(field.Elem(), prefix+key+".", isSet); len(nested) > 0 {
+					table[key] = nested
+				}
+			}
+		} else if isSet(prefix+key, field) {
+			table[key] = field.Interface()
+		}
+	}
+	return table
+}
+
+// ForgeFlags returns the forge command-line flags for the profile's compiler
+// settings and remappings, plus its fuzz settings if test is true (for forge test
+// rather than forge build). Relative remapping targets are joined onto root, the
+// directory containing foundry.toml, so they resolve from the working directory.
+func (c *Config) ForgeFlags(name string, test bool, root string) []string {
+	var flags []string
+	if p := c.ResolveProfile(name); p != nil {
+		if p.Optimizer {
+			flags = append(flags, "--optimize")
+		}
+		if p.OptimizerRuns != 0 {
+			flags = append(flags, "--optimizer-runs", strconv.Itoa(p.OptimizerRuns))
+		}
+		if p.ViaIR {
+			flags = append(flags, "--via-ir")
+		}
+		if p.EvmVersion != "" {
+			flags = append(flags, "--evm-version", p.EvmVersion)
+		}
+		if test {
+			runs := p.FuzzRuns
+			if p.Fuzz != nil && p.Fuzz.Runs != 0 {
+				runs = p.Fuzz.Runs
+			}
+			if runs != 0 {
+				flags = append(flags, "--fuzz-runs", strconv.Itoa(runs))
+			}
+			if p.Fuzz != nil && p.Fuzz.Seed != "" {
+				flags = append(flags, "--fuzz-seed", p.Fuzz.Seed)
+			}
+		}
+	}
+	for _, remapping := range c.GetRemappings(name) {
+		flags = append(flags, "--remappings", rebaseRemapping(remapping, root))
+	}
+	return flags
+}
+
+// rebaseRemapping joins a remapping's relative target onto root.
+func rebaseRemapping(remapping, root string) string {
+	lhs, target, ok := strings.Cut(remapping, "=")
+	if !ok || root == "" || root == "." || target == "" || path.IsAbs(target) {
+		return remapping
+	}
+	rebased := path.Join(root, target)
+	if strings.HasSuffix(target, "/") {
+		rebased += "/"
+	}
+	return lhs + "=" + rebased
+}
+
+// ShellJoin quotes each argument for a POSIX shell and joins them with spaces.
+func ShellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_.,:/@%+=-]+$`)
+
+// shellQuote quotes s for a POSIX shell, leaving it alone if that isn't needed.
+func shellQuote(s string) string {
+	if s != "" && shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
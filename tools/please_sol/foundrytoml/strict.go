@@ -0,0 +1,191 @@
+package foundrytoml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+
+	"tools/please_sol/editdistance"
+)
+
+// unmodelled lists valid Foundry keys that Config doesn't model, by table. Map
+// entries (profile names, etherscan chains) appear as "*". Check accepts these;
+// everything else is ignored by Foundry itself or is a typo.
+var unmodelled = map[string][]string{
+	"": {
+		"bind_json", "dependencies", "doc", "fuzz", "invariant", "labels", "lint", "soldeer", "vyper",
+	},
+	"profile.*": {
+		"additional_compiler_profiles", "allow_internal_expect_revert", "allow_paths", "always_use_create_2_factory",
+		"assertions_revert", "ast", "auto_detect_remappings", "auto_detect_solc", "block_base_fee_per_gas",
+		"block_coinbase", "block_difficulty", "block_gas_limit", "block_number", "block_prevrandao",
+		"block_timestamp", "broadcast", "build_info", "build_info_path", "bytecode_hash", "cbor_metadata",
+		"chain_id", "code_size_limit", "compilation_restrictions", "compute_units_per_second", "deny_warnings",
+		"disable_block_gas_limit", "dynamic_test_linking", "eth_rpc_url", "etherscan_api_key", "extra_output",
+		"extra_output_files", "ffi", "force", "fork_block_number", "fs_permissions", "gas_limit", "gas_price",
+		"gas_reports", "gas_reports_ignore", "ignored_error_codes", "ignored_warnings_from", "include_paths",
+		"initial_balance", "isolate", "legacy_assertions", "libraries", "match_path", "memory_limit",
+		"model_checker", "names", "no_match_contract", "no_match_coverage", "no_match_path",
+		"no_rpc_rate_limit", "no_storage_caching", "offline", "prompt_timeout", "revert_strings",
+		"rpc_storage_caching", "script", "sender", "show_progress", "sizes", "skip", "snapshots", "sparse_mode",
+		"test_failures_file", "threads", "transaction_timeout", "tx_origin", "unchecked_cheatcode_artifacts",
+		"use_literal_content",
+	},
+	"profile.*.fuzz": {
+		"dictionary_weight", "failure_persist_dir", "failure_persist_file", "gas_report_samples",
+		"include_push_bytes", "include_storage", "max_fuzz_dictionary_addresses", "max_fuzz_dictionary_values",
+		"show_logs", "timeout",
+	},
+	"profile.*.invariant": {
+		"call_override", "dictionary_weight", "failure_persist_dir", "gas_report_samples", "include_push_bytes",
+		"include_storage", "max_assume_rejects", "max_fuzz_dictionary_addresses", "max_fuzz_dictionary_values",
+		"shrink_run_limit", "show_metrics", "show_solidity", "timeout",
+	},
+	"profile.*.optimizer_details": {"simple_counter_for_loop_unchecked_increment", "yul_details"},
+	"profile.*.fmt":               {"docs_style", "pow_no_space", "prefer_compact", "single_line_imports", "style"},
+	"fmt":                         {"docs_style", "pow_no_space", "prefer_compact", "single_line_imports", "style"},
+}
+
+// UnknownKey is a key in foundry.toml that doesn't match any known setting.
+type UnknownKey struct {
+	// Key is the full dotted key, e.g. "profile.default.optimiser_runs".
+	Key    string
+	Line   int
+	Column int
+	// Suggestion is the closest known key in the same table, if any is close enough.
+	Suggestion string
+}
+
+func (u UnknownKey) String() string {
+	msg := fmt.Sprintf("%d:%d: unknown key %s", u.Line, u.Column, u.Key)
+	if u.Suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean %s?)", u.Suggestion)
+	}
+	return msg
+}
+
+// CheckFile checks a foundry.toml file for unknown keys.
+func CheckFile(path string) ([]UnknownKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read foundry.toml: %w", err)
+	}
+	return Check(data)
+}
+
+// Check returns every key in foundry.toml content that Foundry doesn't recognise,
+// sorted by position. Parse silently ignores these, so they're usually typos.
+func Check(data []byte) ([]UnknownKey, error) {
+	dec := toml.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var config Config
+	err := dec.Decode(&config)
+	var strict *toml.StrictMissingError
+	if !errors.As(err, &strict) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse foundry.toml: %w", err)
+		}
+		return nil, nil
+	}
+
+	var unknown []UnknownKey
+	for _, e := range strict.Errors {
+		key := e.Key()
+		if len(key) == 0 {
+			continue
+		}
+		table, known := tableKeys(key[:len(key)-1])
+		leaf := key[len(key)-1]
+		if slices.Contains(unmodelled[table], leaf) {
+			continue
+		}
+		candidates := append(known, unmodelled[table]...)
+		if table == "profile.*" {
+			if _, ok := aliases[leaf]; ok {
+				continue
+			}
+			for alias := range aliases {
+				candidates = append(candidates, alias)
+			}
+		}
+		line, col := e.Position()
+		unknown = append(unknown, UnknownKey{
+			Key:        strings.Join(key, "."),
+			Line:       line,
+			Column:     col,
+			Suggestion: closest(leaf, candidates),
+		})
+	}
+	sort.SliceStable(unknown, func(i, j int) bool {
+		if unknown[i].Line != unknown[j].Line {
+			return unknown[i].Line < unknown[j].Line
+		}
+		return unknown[i].Column < unknown[j].Column
+	})
+	return unknown, nil
+}
+
+// tableKeys returns the normalised name of the table at path (with map keys such
+// as profile names replaced by "*") and the keys Config models in it.
+func tableKeys(path []string) (string, []string) {
+	t := reflect.TypeOf(Config{})
+	normalised := make([]string, 0, len(path))
+	for _, segment := range path {
+		for t != nil && t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch {
+		case t != nil && t.Kind() == reflect.Map:
+			normalised = append(normalised, "*")
+			t = t.Elem()
+		case t != nil && t.Kind() == reflect.Struct:
+			normalised = append(normalised, segment)
+			t = fieldTypeByTag(t, segment)
+		default:
+			normalised = append(normalised, segment)
+			t = nil
+		}
+	}
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var keys []string
+	if t != nil && t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			if key, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ","); key != "" && key != "-" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return strings.Join(normalised, "."), keys
+}
+
+// fieldTypeByTag returns the type of the struct field with the given TOML key, or nil.
+func fieldTypeByTag(t reflect.Type, key string) reflect.Type {
+	for i := 0; i < t.NumField(); i++ {
+		if tag, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ","); tag == key {
+			return t.Field(i).Type
+		}
+	}
+	return nil
+}
+
+// closest returns the candidate nearest to key by edit distance, or "" if none is
+// close enough to plausibly be what was meant.
+func closest(key string, candidates []string) string {
+	best, bestDistance := "", max(1, len(key)/3)+1
+	for _, c := range candidates {
+		if d := editdistance.Distance(key, c); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
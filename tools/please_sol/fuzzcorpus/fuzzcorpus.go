@@ -0,0 +1,276 @@
+// Package fuzzcorpus saves the inputs that fuzz and invariant tests failed with,
+// so that the failures can be replayed deterministically on another machine.
+package fuzzcorpus
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tools/please_sol/listtests"
+	"tools/please_sol/testreport"
+)
+
+// persistDirs are where forge persists fuzz and invariant failures by default,
+// relative to its root. forge replays them before fuzzing anything new.
+var persistDirs = []string{"cache/fuzz", "cache/invariant"}
+
+// Corpus is the failures from a forge test run, with what's needed to replay them.
+type Corpus struct {
+	// Seed is the --fuzz-seed the tests ran with.
+	Seed     string    `json:"seed"`
+	Failures []Failure `json:"failures"`
+	// Persisted are the contents of forge's own failure files, keyed by their path
+	// relative to its root.
+	Persisted map[string]string `json:"persisted,omitempty"`
+}
+
+// Failure is a fuzz or invariant test that failed.
+type Failure struct {
+	// Suite is "path/File.t.sol:Contract".
+	Suite  string `json:"suite"`
+	Test   string `json:"test"`
+	Kind   string `json:"kind"`
+	Reason string `json:"reason,omitempty"`
+	// Calls are the failing input of a fuzz test, or an invariant test's call sequence.
+	Calls []testreport.Call `json:"calls"`
+}
+
+// NewSeed returns a random fuzz seed in the hex form forge accepts.
+func NewSeed() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("0x%x", binary.BigEndian.Uint64(b[:]))
+}
+
+// FromReport returns a corpus of the failed fuzz and invariant tests in report,
+// which ran with seed. report may be nil if forge didn't produce results.
+func FromReport(report *testreport.Report, seed string) *Corpus {
+	c := &Corpus{Seed: seed, Failures: []Failure{}}
+	if report == nil {
+		return c
+	}
+	for _, s := range report.Suites {
+		for _, t := range s.Tests {
+			if t.Status != testreport.StatusFailure || (t.Kind != "fuzz" && t.Kind != "invariant") {
+				continue
+			}
+			c.Failures = append(c.Failures, Failure{Suite: s.Name, Test: t.Name, Kind: t.Kind, Reason: t.Reason, Calls: t.Calls})
+		}
+	}
+	return c
+}
+
+// Session is the fuzz corpus side of one forge test run: the seed it runs with
+// and, if it replays a corpus, that corpus.
+type Session struct {
+	Seed string
+	// Replay is the corpus file given with --replay, if any, and Replayed its contents.
+	Replay   string
+	Replayed *Corpus
+}
+
+// Start prepares a forge test command so that its fuzz failures can be replayed.
+// If the command has a --replay=FILE argument, it's removed, the corpus in FILE
+// is restored under root and the command is given its seed and filters for its
+// failed tests. Otherwise the command is given a new --fuzz-seed unless it has one.
+func Start(command []string, root string) (*Session, []string, error) {
+	s := &Session{}
+	s.Replay, command = CutReplay(command)
+	if s.Replay != "" {
+		corpus, err := Load(Locate(s.Replay))
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := corpus.Restore(root); err != nil {
+			return nil, nil, err
+		}
+		s.Seed, s.Replayed = corpus.Seed, corpus
+		return s, append(command, corpus.Args()...), nil
+	}
+	if seed, ok := FindSeed(command); ok {
+		s.Seed = seed
+		return s, command, nil
+	}
+	s.Seed = NewSeed()
+	return s, append(command, "--fuzz-seed", s.Seed), nil
+}
+
+// Save writes the failed fuzz and invariant tests in report to filename, with
+// the failure files forge persisted under root, and returns the corpus. report is
+// nil if forge didn't produce results, e.g. because it crashed; the seed is still
+// saved, and a replay keeps the failures it replayed so that they can be replayed
+// again.
+func (s *Session) Save(report *testreport.Report, root, filename string) (*Corpus, error) {
+	c := FromReport(report, s.Seed)
+	if report == nil && s.Replayed != nil {
+		c.Failures = s.Replayed.Failures
+	}
+	if err := c.Collect(root); err != nil {
+		return nil, err
+	}
+	if err := c.Write(filename); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Saved returns a note that the corpus has failures saved to filename and how to
+// replay them, or "" if it has none.
+func (c *Corpus) Saved(filename string) string {
+	if len(c.Failures) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Saved %d failed fuzz test(s) with seed %s to the %s test output; to replay them, pass --replay=<a copy of it> to plz test\n",
+		len(c.Failures), c.Seed, filepath.Base(filename))
+}
+
+// Collect adds the failure files forge persisted under root.
+func (c *Corpus) Collect(root string) error {
+	for _, dir := range persistDirs {
+		err := filepath.WalkDir(filepath.Join(root, dir), func(path string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			} else if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if c.Persisted == nil {
+				c.Persisted = map[string]string{}
+			}
+			c.Persisted[filepath.ToSlash(rel)] = string(data)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to collect forge's persisted failures: %w", err)
+		}
+	}
+	return nil
+}
+
+// Restore writes the persisted failure files back under root, for forge to replay.
+func (c *Corpus) Restore(root string) error {
+	for rel, data := range c.Persisted {
+		if !isPersistPath(rel) {
+			return fmt.Errorf("unexpected persisted failure file %q", rel)
+		}
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", rel, err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", rel, err)
+		}
+	}
+	return nil
+}
+
+// isPersistPath returns true if rel is under one of forge's persist directories,
+// so that a corpus can't write anywhere else.
+func isPersistPath(rel string) bool {
+	clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(rel)))
+	for _, dir := range persistDirs {
+		if strings.HasPrefix(clean, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Args returns the forge test arguments that replay the corpus: its seed and,
+// if it has failures, filters that match only the failed tests.
+func (c *Corpus) Args() []string {
+	var selectors []string
+	for _, f := range c.Failures {
+		contract := f.Suite[strings.LastIndex(f.Suite, ":")+1:]
+		name, _, _ := strings.Cut(f.Test, "(")
+		selectors = append(selectors, contract+"."+name)
+	}
+	return append([]string{"--fuzz-seed", c.Seed}, listtests.FilterArgs(selectors)...)
+}
+
+// Load reads a corpus file.
+func Load(filename string) (*Corpus, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fuzz corpus: %w", err)
+	}
+	c := &Corpus{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse fuzz corpus %s: %w", filename, err)
+	}
+	if c.Seed == "" {
+		return nil, fmt.Errorf("fuzz corpus %s has no seed", filename)
+	}
+	return c, nil
+}
+
+// Write writes the corpus to a file.
+func (c *Corpus) Write(filename string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fuzz corpus: %w", err)
+	}
+	if err := os.WriteFile(filename, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write fuzz corpus: %w", err)
+	}
+	return nil
+}
+
+// CutReplay removes a --replay=FILE or --replay FILE argument from args, which
+// is how a corpus is passed to plz test, and returns FILE if there was one.
+func CutReplay(args []string) (filename string, rest []string) {
+	for i := 0; i < len(args); i++ {
+		if value, ok := strings.CutPrefix(args[i], "--replay="); ok {
+			filename = value
+		} else if args[i] == "--replay" && i+1 < len(args) {
+			filename = args[i+1]
+			i++
+		} else {
+			rest = append(rest, args[i])
+		}
+	}
+	return filename, rest
+}
+
+// FindSeed returns the value of a --fuzz-seed argument in args.
+func FindSeed(args []string) (string, bool) {
+	for i, arg := range args {
+		if value, ok := strings.CutPrefix(arg, "--fuzz-seed="); ok {
+			return value, true
+		} else if arg == "--fuzz-seed" && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+// Locate returns where to find a corpus given to plz test. Tests run under
+// plz-out, so a relative path that doesn't exist there is taken to be relative
+// to the repo root.
+func Locate(filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	if _, err := os.Stat(filename); err == nil {
+		return filename
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if root, _, ok := strings.Cut(wd, string(filepath.Separator)+"plz-out"+string(filepath.Separator)); ok {
+			return filepath.Join(root, filename)
+		}
+	}
+	return filename
+}
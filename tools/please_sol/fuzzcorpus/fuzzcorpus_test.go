@@ -0,0 +1,166 @@
+package fuzzcorpus
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tools/please_sol/testreport"
+)
+
+func testReport() *testreport.Report {
+	return &testreport.Report{Suites: []testreport.Suite{{
+		Name: "test/05_advanced/Fuzz.t.sol:FuzzTest",
+		Tests: []testreport.Test{
+			{Name: "testFuzz_Deposit(uint256)", Status: testreport.StatusFailure, Kind: "fuzz", Reason: "overflow",
+				Calls: []testreport.Call{{Calldata: "0xb6b55f25", Args: "1"}}},
+			{Name: "invariant_Total()", Status: testreport.StatusFailure, Kind: "invariant",
+				Calls: []testreport.Call{{Sender: "0x01", Calldata: "0xaa"}, {Sender: "0x02", Calldata: "0xbb"}}},
+			{Name: "test_Unit()", Status: testreport.StatusFailure, Kind: "unit"},
+			{Name: "testFuzz_Ok(uint256)", Status: testreport.StatusSuccess, Kind: "fuzz"},
+		},
+	}}}
+}
+
+func TestFromReport(t *testing.T) {
+	c := FromReport(testReport(), "0x2a")
+	if len(c.Failures) != 2 {
+		t.Fatalf("expected the 2 failed fuzz and invariant tests, got %+v", c.Failures)
+	}
+	if f := c.Failures[1]; f.Test != "invariant_Total()" || len(f.Calls) != 2 {
+		t.Errorf("expected the invariant test's sequence, got %+v", f)
+	}
+	if c := FromReport(nil, "0x2a"); c.Failures == nil || len(c.Failures) != 0 {
+		t.Errorf("expected no failures without a report, got %+v", c.Failures)
+	}
+}
+
+func TestArgs(t *testing.T) {
+	c := FromReport(testReport(), "0x2a")
+	expected := []string{
+		"--fuzz-seed", "0x2a",
+		"--match-test", "^(invariant_Total|testFuzz_Deposit)$",
+		"--match-contract", "^(FuzzTest)$",
+	}
+	if args := c.Args(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+	if args := (&Corpus{Seed: "7"}).Args(); !reflect.DeepEqual(args, []string{"--fuzz-seed", "7"}) {
+		t.Errorf("expected only the seed without failures, got %q", args)
+	}
+}
+
+func TestCollectRestore(t *testing.T) {
+	dir := t.TempDir()
+	failures := filepath.Join(dir, "cache/invariant/failures/FuzzTest/invariant_Total")
+	os.MkdirAll(filepath.Dir(failures), 0755)
+	os.WriteFile(failures, []byte(`[{"sender":"0x01"}]`), 0644)
+
+	c := FromReport(testReport(), "0x2a")
+	if err := c.Collect(dir); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	corpus := filepath.Join(dir, "fuzz-failures.json")
+	if err := c.Write(corpus); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	loaded, err := Load(corpus)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, c) {
+		t.Errorf("expected %+v, got %+v", c, loaded)
+	}
+
+	replay := t.TempDir()
+	if err := loaded.Restore(replay); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(replay, "cache/invariant/failures/FuzzTest/invariant_Total"))
+	if err != nil || string(data) != `[{"sender":"0x01"}]` {
+		t.Errorf("expected the failure file to be restored, got %q (%v)", data, err)
+	}
+
+	bad := &Corpus{Seed: "1", Persisted: map[string]string{"cache/fuzz/../../evil": ""}}
+	if err := bad.Restore(replay); err == nil {
+		t.Error("expected an error restoring a file outside forge's cache")
+	}
+}
+
+func TestCutReplay(t *testing.T) {
+	for _, args := range [][]string{
+		{"test", "--replay=fuzz.json", "-vv"},
+		{"test", "--replay", "fuzz.json", "-vv"},
+	} {
+		filename, rest := CutReplay(args)
+		if filename != "fuzz.json" || !reflect.DeepEqual(rest, []string{"test", "-vv"}) {
+			t.Errorf("%q: expected fuzz.json and [test -vv], got %q and %q", args, filename, rest)
+		}
+	}
+	if seed, ok := FindSeed([]string{"test", "--fuzz-seed", "0x1"}); !ok || seed != "0x1" {
+		t.Errorf("expected seed 0x1, got %q", seed)
+	}
+}
+
+func TestSession(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "fuzz-failures.json")
+
+	s, command, err := Start([]string{"forge", "test"}, dir)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if seed, ok := FindSeed(command); !ok || seed != s.Seed || s.Seed == "" {
+		t.Errorf("expected the command to be given the session's seed %q, got %q", s.Seed, command)
+	}
+	if _, command, _ := Start([]string{"forge", "test", "--fuzz-seed=0x7"}, dir); len(command) != 3 {
+		t.Errorf("expected an existing seed to be kept, got %q", command)
+	}
+
+	// forge crashed before writing any results: the seed is still saved.
+	c, err := s.Save(nil, dir, corpus)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if loaded, err := Load(corpus); err != nil || loaded.Seed != s.Seed || len(loaded.Failures) != 0 {
+		t.Errorf("expected the seed to be saved without failures, got %+v (%v)", loaded, err)
+	}
+	if note := c.Saved(corpus); note != "" {
+		t.Errorf("expected no note without failures, got %q", note)
+	}
+
+	c, err = s.Save(testReport(), dir, corpus)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if note := c.Saved(corpus); !strings.HasPrefix(note, "Saved 2 failed fuzz test(s) with seed "+s.Seed+" to the fuzz-failures.json test output") {
+		t.Errorf("unexpected note %q", note)
+	}
+
+	// Replaying narrows forge to the failed tests, and still does after a crash.
+	replay, command, err := Start([]string{"forge", "test", "--replay=" + corpus, "-vv"}, t.TempDir())
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	expected := append([]string{"forge", "test", "-vv"}, c.Args()...)
+	if replay.Seed != s.Seed || !reflect.DeepEqual(command, expected) {
+		t.Errorf("expected %q with seed %s, got %q with seed %s", expected, s.Seed, command, replay.Seed)
+	}
+	again := filepath.Join(dir, "again.json")
+	if _, err := replay.Save(nil, dir, again); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(again)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Args(), c.Args()) {
+		t.Errorf("expected the replayed failures to be kept, got %q", loaded.Args())
+	}
+
+	if _, _, err := Start([]string{"forge", "test", "--replay", filepath.Join(dir, "missing.json")}, dir); err == nil {
+		t.Error("expected an error for a missing corpus")
+	}
+}
@@ -0,0 +1,337 @@
+// Package gasreport merges the gas reports from forge test --gas-report runs, so
+// that the gas used by each contract's functions can be seen across every test.
+package gasreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Report is the gas used by the contracts a set of tests called.
+type Report struct {
+	// Contracts are sorted by name.
+	Contracts []*Contract
+}
+
+// Contract is the gas used by one contract, named "path/File.sol:Contract".
+// Its JSON matches forge's, so that merged reports can be merged again.
+type Contract struct {
+	Name       string     `json:"contract"`
+	Deployment Deployment `json:"deployment"`
+	// Functions are keyed by name, or by signature if forge gave one.
+	Functions map[string]Function `json:"functions"`
+}
+
+// Deployment is the cost of deploying a contract.
+type Deployment struct {
+	Gas uint64 `json:"gas"`
+	// Size is the deployed code's size in bytes.
+	Size uint64 `json:"size"`
+}
+
+// Function is the gas used by calls to one function.
+type Function struct {
+	Calls  uint64 `json:"calls"`
+	Min    uint64 `json:"min"`
+	Mean   uint64 `json:"mean"`
+	Median uint64 `json:"median"`
+	Max    uint64 `json:"max"`
+}
+
+// Parse reads the output of forge test --gas-report, either as the JSON that
+// --json gives or as the tables forge prints otherwise. Other output, such as
+// compiler and test results, is skipped.
+func Parse(data []byte) (*Report, error) {
+	if start, ok := jsonStart(data); ok {
+		return parseJSON(data[start:])
+	}
+	report := parseTables(data)
+	if len(report.Contracts) == 0 {
+		return nil, fmt.Errorf("no gas report found in forge output; was it run with --gas-report?")
+	}
+	return report, nil
+}
+
+// Load reads a file of forge test --gas-report output.
+func Load(filename string) (*Report, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gas report: %w", err)
+	}
+	report, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return report, nil
+}
+
+// jsonPattern matches the start of forge's JSON gas report, which is an array
+// of contracts. Test results such as "[PASS] test()" don't match.
+var jsonPattern = regexp.MustCompile(`(?m)^\[\s*[{\]]`)
+
+// jsonStart returns where the JSON array in data starts, if there is one.
+func jsonStart(data []byte) (int, bool) {
+	if loc := jsonPattern.FindIndex(data); loc != nil {
+		return loc[0], true
+	}
+	return 0, false
+}
+
+// forgeFunction is a function's gas as forge serialises it. The mean isn't
+// always a whole number.
+type forgeFunction struct {
+	Calls  uint64  `json:"calls"`
+	Min    uint64  `json:"min"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	Max    uint64  `json:"max"`
+}
+
+func parseJSON(data []byte) (*Report, error) {
+	var contracts []struct {
+		Name       string                     `json:"contract"`
+		Deployment Deployment                 `json:"deployment"`
+		Functions  map[string]json.RawMessage `json:"functions"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&contracts); err != nil {
+		return nil, fmt.Errorf("failed to parse gas report: %w", err)
+	}
+	report := &Report{}
+	for _, c := range contracts {
+		contract := &Contract{Name: c.Name, Deployment: c.Deployment, Functions: map[string]Function{}}
+		if err := addFunctions(contract, c.Functions); err != nil {
+			return nil, fmt.Errorf("failed to parse gas report for %s: %w", c.Name, err)
+		}
+		report.Contracts = append(report.Contracts, contract)
+	}
+	report.sort()
+	return report, nil
+}
+
+// addFunctions adds forge's functions to a contract. Some versions of forge
+// group overloaded functions under their name, keyed by signature, so a value
+// without any calls is read as a group.
+func addFunctions(contract *Contract, functions map[string]json.RawMessage) error {
+	for name, data := range functions {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+		if _, ok := fields["calls"]; !ok {
+			if err := addFunctions(contract, fields); err != nil {
+				return err
+			}
+			continue
+		}
+		var f forgeFunction
+		if err := json.Unmarshal(data, &f); err != nil {
+			return err
+		}
+		contract.Functions[name] = Function{
+			Calls:  f.Calls,
+			Min:    f.Min,
+			Mean:   uint64(f.Mean + 0.5),
+			Median: uint64(f.Median + 0.5),
+			Max:    f.Max,
+		}
+	}
+	return nil
+}
+
+var (
+	// separatorPattern matches the cells of a table's border rows, e.g. "-----" or "=====".
+	separatorPattern = regexp.MustCompile(`^[-=+:─═ ]*$`)
+	// contractPattern matches a table's title, e.g. "src/Counter.sol:Counter contract".
+	contractPattern = regexp.MustCompile(`^(\S+) [Cc]ontract$`)
+)
+
+// parseTables reads the tables forge prints, one per contract:
+//
+//	| src/Counter.sol:Counter Contract |                 |       |        |       |         |
+//	|----------------------------------+-----------------+-------+--------+-------+---------|
+//	| Deployment Cost                  | Deployment Size |       |        |       |         |
+//	| 106715                           | 277             |       |        |       |         |
+//	| Function Name                    | Min             | Avg   | Median | Max   | # Calls |
+//	| increment                        | 43404           | 43404 | 43404  | 43404 | 1       |
+func parseTables(data []byte) *Report {
+	report := &Report{}
+	var contract *Contract
+	section := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		cells, ok := tableCells(line)
+		if !ok {
+			continue
+		}
+		if m := contractPattern.FindStringSubmatch(cells[0]); m != nil {
+			contract = &Contract{Name: m[1], Functions: map[string]Function{}}
+			report.Contracts = append(report.Contracts, contract)
+			section = ""
+			continue
+		}
+		if contract == nil {
+			continue
+		}
+		switch {
+		case cells[0] == "Deployment Cost":
+			section = "deployment"
+		case strings.EqualFold(cells[0], "Function Name"):
+			section = "functions"
+		case section == "deployment" && len(cells) >= 2:
+			contract.Deployment.Gas = parseUint(cells[0])
+			contract.Deployment.Size = parseUint(cells[1])
+			section = ""
+		case section == "functions" && len(cells) >= 6:
+			contract.Functions[cells[0]] = Function{
+				Min:    parseUint(cells[1]),
+				Mean:   parseUint(cells[2]),
+				Median: parseUint(cells[3]),
+				Max:    parseUint(cells[4]),
+				Calls:  parseUint(cells[5]),
+			}
+		}
+	}
+	report.sort()
+	return report
+}
+
+// tableCells splits a table row into its trimmed cells. It returns false for
+// lines that aren't rows with content, including borders.
+func tableCells(line string) ([]string, bool) {
+	line = strings.TrimSpace(ansiPattern.ReplaceAllString(line, ""))
+	line = strings.NewReplacer("│", "|", "║", "|").Replace(line)
+	if !strings.HasPrefix(line, "|") {
+		return nil, false
+	}
+	cells := strings.Split(strings.Trim(line, "|"), "|")
+	empty := true
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+		if !separatorPattern.MatchString(cells[i]) {
+			empty = false
+		}
+	}
+	return cells, !empty
+}
+
+// ansiPattern matches the colour codes forge adds to its output on a terminal.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func parseUint(s string) uint64 {
+	n, _ := strconv.ParseUint(s, 10, 64)
+	return n
+}
+
+func (r *Report) sort() {
+	sort.Slice(r.Contracts, func(i, j int) bool { return r.Contracts[i].Name < r.Contracts[j].Name })
+}
+
+// Merge merges reports into one, combining each contract's functions across
+// them. Calls, min and max are exact, and the mean is weighted by calls. The
+// median can't be recovered from the reports' medians, so it's the median of
+// them weighted by calls. A contract's deployment is the most expensive seen.
+func Merge(reports ...*Report) *Report {
+	contracts := map[string]*Contract{}
+	runs := map[string]map[string][]Function{}
+	for _, report := range reports {
+		for _, c := range report.Contracts {
+			merged, ok := contracts[c.Name]
+			if !ok {
+				merged = &Contract{Name: c.Name, Functions: map[string]Function{}}
+				contracts[c.Name] = merged
+				runs[c.Name] = map[string][]Function{}
+			}
+			if c.Deployment.Gas > merged.Deployment.Gas {
+				merged.Deployment = c.Deployment
+			}
+			for name, f := range c.Functions {
+				runs[c.Name][name] = append(runs[c.Name][name], f)
+			}
+		}
+	}
+
+	merged := &Report{}
+	for name, c := range contracts {
+		for function, fs := range runs[name] {
+			c.Functions[function] = mergeFunction(fs)
+		}
+		merged.Contracts = append(merged.Contracts, c)
+	}
+	merged.sort()
+	return merged
+}
+
+func mergeFunction(runs []Function) Function {
+	merged := Function{Min: runs[0].Min}
+	var total float64
+	for _, f := range runs {
+		merged.Calls += f.Calls
+		merged.Min = min(merged.Min, f.Min)
+		merged.Max = max(merged.Max, f.Max)
+		total += float64(f.Mean) * float64(f.Calls)
+	}
+	if merged.Calls == 0 {
+		merged.Mean, merged.Median = runs[0].Mean, runs[0].Median
+		return merged
+	}
+	merged.Mean = uint64(total/float64(merged.Calls) + 0.5)
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Median < runs[j].Median })
+	var calls uint64
+	for _, f := range runs {
+		if calls += f.Calls; 2*calls >= merged.Calls {
+			merged.Median = f.Median
+			break
+		}
+	}
+	return merged
+}
+
+// WriteJSON writes the report in the format of forge's JSON gas report.
+func (r *Report) WriteJSON(w io.Writer) error {
+	contracts := r.Contracts
+	if contracts == nil {
+		contracts = []*Contract{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(contracts); err != nil {
+		return fmt.Errorf("failed to write gas report: %w", err)
+	}
+	return nil
+}
+
+// WriteMarkdown writes the report as a Markdown table per contract.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Gas Report\n")
+	if len(r.Contracts) == 0 {
+		b.WriteString("\nNo contracts were called.\n")
+	}
+	for _, c := range r.Contracts {
+		fmt.Fprintf(&b, "\n## %s\n\n", c.Name)
+		fmt.Fprintf(&b, "Deployment cost: %d gas, size: %d bytes\n", c.Deployment.Gas, c.Deployment.Size)
+		if len(c.Functions) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(c.Functions))
+		for name := range c.Functions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		b.WriteString("\n| Function | Min | Avg | Median | Max | Calls |\n")
+		b.WriteString("|----------|----:|----:|-------:|----:|------:|\n")
+		for _, name := range names {
+			f := c.Functions[name]
+			fmt.Fprintf(&b, "| `%s` | %d | %d | %d | %d | %d |\n", name, f.Min, f.Mean, f.Median, f.Max, f.Calls)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
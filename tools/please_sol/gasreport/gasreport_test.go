@@ -0,0 +1,139 @@
+package gasreport
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// forgeTables is trimmed from forge test --gas-report.
+const forgeTables = `Compiling 3 files with Solc 0.8.20
+Ran 2 tests for test/Counter.t.sol:CounterTest
+[PASS] test_Increment() (gas: 31303)
+╭----------------------------------+-----------------+-------+--------+-------+---------╮
+| src/Counter.sol:Counter Contract |                 |       |        |       |         |
++========================================================================================+
+| Deployment Cost                  | Deployment Size |       |        |       |         |
+|----------------------------------+-----------------+-------+--------+-------+---------|
+| 106715                           | 277             |       |        |       |         |
+|----------------------------------+-----------------+-------+--------+-------+---------|
+|                                  |                 |       |        |       |         |
+|----------------------------------+-----------------+-------+--------+-------+---------|
+| Function Name                    | Min             | Avg   | Median | Max   | # Calls |
+|----------------------------------+-----------------+-------+--------+-------+---------|
+| increment                        | 23404           | 33404 | 43404  | 43404 | 2       |
+|----------------------------------+-----------------+-------+--------+-------+---------|
+| number                           | 2424            | 2424  | 2424   | 2424  | 1       |
+╰----------------------------------+-----------------+-------+--------+-------+---------╯
+`
+
+// forgeJSON is trimmed from forge test --gas-report --json.
+const forgeJSON = `No files changed, compilation skipped
+[{"contract":"src/Counter.sol:Counter","deployment":{"gas":106715,"size":277},"functions":{
+"increment":{"calls":6,"min":21000,"mean":25000.4,"median":26000,"max":50000},
+"setNumber":{"setNumber(uint256)":{"calls":1,"min":100,"mean":100,"median":100,"max":100},"setNumber(uint8)":{"calls":1,"min":90,"mean":90,"median":90,"max":90}}}},
+{"contract":"src/Token.sol:Token","deployment":{"gas":500000,"size":2000},"functions":{}}]
+`
+
+func TestParse(t *testing.T) {
+	report, err := Parse([]byte(forgeTables))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(report.Contracts) != 1 {
+		t.Fatalf("expected 1 contract, got %d", len(report.Contracts))
+	}
+	c := report.Contracts[0]
+	if c.Name != "src/Counter.sol:Counter" || c.Deployment != (Deployment{Gas: 106715, Size: 277}) {
+		t.Errorf("unexpected contract %+v", c)
+	}
+	expected := Function{Calls: 2, Min: 23404, Mean: 33404, Median: 43404, Max: 43404}
+	if f := c.Functions["increment"]; f != expected {
+		t.Errorf("expected %+v, got %+v", expected, f)
+	}
+	if len(c.Functions) != 2 {
+		t.Errorf("expected 2 functions, got %v", c.Functions)
+	}
+}
+
+func TestParse_JSON(t *testing.T) {
+	report, err := Parse([]byte(forgeJSON))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(report.Contracts) != 2 {
+		t.Fatalf("expected 2 contracts, got %d", len(report.Contracts))
+	}
+	c := report.Contracts[0]
+	if f := c.Functions["increment"]; f.Mean != 25000 || f.Calls != 6 {
+		t.Errorf("unexpected increment %+v", f)
+	}
+	if f := c.Functions["setNumber(uint8)"]; f.Calls != 1 || f.Max != 90 {
+		t.Errorf("expected overloads to be read by signature, got %v", c.Functions)
+	}
+}
+
+func TestParse_NoReport(t *testing.T) {
+	if _, err := Parse([]byte("Ran 1 test for test/Counter.t.sol:CounterTest\n")); err == nil {
+		t.Error("expected an error for output without a gas report")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tables, err := Parse([]byte(forgeTables))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	jsonReport, err := Parse([]byte(forgeJSON))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	merged := Merge(tables, jsonReport)
+	if len(merged.Contracts) != 2 {
+		t.Fatalf("expected 2 contracts, got %d", len(merged.Contracts))
+	}
+	// 2 calls at a mean of 33404 and 6 at 25000; the 6 calls have the median.
+	expected := Function{Calls: 8, Min: 21000, Mean: 27101, Median: 26000, Max: 50000}
+	if f := merged.Contracts[0].Functions["increment"]; f != expected {
+		t.Errorf("expected %+v, got %+v", expected, f)
+	}
+	if f := merged.Contracts[0].Functions["number"]; f.Calls != 1 || f.Median != 2424 {
+		t.Errorf("expected number to be unchanged, got %+v", f)
+	}
+
+	// Merged reports are written in forge's format, so can be read back.
+	var out strings.Builder
+	if err := merged.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	reread, err := Parse([]byte(out.String()))
+	if err != nil {
+		t.Fatalf("failed to parse merged report: %v\n%s", err, out.String())
+	}
+	if f := reread.Contracts[0].Functions["increment"]; f != expected {
+		t.Errorf("expected %+v after reading back, got %+v", expected, f)
+	}
+	if !json.Valid([]byte(out.String())) {
+		t.Errorf("invalid JSON:\n%s", out.String())
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	report, err := Parse([]byte(forgeTables))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var out strings.Builder
+	if err := report.WriteMarkdown(&out); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	for _, want := range []string{
+		"## src/Counter.sol:Counter\n",
+		"Deployment cost: 106715 gas, size: 277 bytes\n",
+		"| `increment` | 23404 | 33404 | 43404 | 43404 | 2 |\n| `number` |",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected Markdown to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
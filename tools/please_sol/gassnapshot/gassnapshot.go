@@ -0,0 +1,194 @@
+// Package gassnapshot compares the .gas-snapshot files written by forge snapshot,
+// to catch tests whose gas use has gone up.
+package gassnapshot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Entry is one test's line in a snapshot, e.g.
+// "CounterTest:test_Increment() (gas: 31303)".
+type Entry struct {
+	// Test is "Contract:function(args)".
+	Test string
+	// Gas is what a unit test used, or the mean over a fuzz test's runs.
+	Gas uint64
+	// HasGas is false for invariant tests, whose lines have no gas.
+	HasGas bool
+}
+
+var (
+	linePattern = regexp.MustCompile(`^(\S+) \((.*)\)$`)
+	// gasPattern matches a unit test's gas or a fuzz test's mean.
+	gasPattern = regexp.MustCompile(`(?:gas|μ): (\d+)`)
+)
+
+// Parse reads a snapshot.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		m := linePattern.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: expected \"Contract:test() (gas: N)\", got %q", n, line)
+		}
+		entry := Entry{Test: m[1]}
+		if gas := gasPattern.FindStringSubmatch(m[2]); gas != nil {
+			entry.Gas, _ = strconv.ParseUint(gas[1], 10, 64)
+			entry.HasGas = true
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read gas snapshot: %w", err)
+	}
+	return entries, nil
+}
+
+// Load reads a snapshot file.
+func Load(filename string) ([]Entry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gas snapshot: %w", err)
+	}
+	defer f.Close()
+	entries, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return entries, nil
+}
+
+// Thresholds are how much a test's gas may rise before it's a regression. If
+// neither is set, any rise is.
+type Thresholds struct {
+	// Percent is the largest allowed rise as a percentage of the golden gas, or 0 if unset.
+	Percent float64
+	// Gas is the largest allowed rise in gas, or 0 if unset.
+	Gas uint64
+}
+
+// exceeded returns true if c rises past either threshold.
+func (t Thresholds) exceeded(c Change) bool {
+	if c.Delta() <= 0 {
+		return false
+	}
+	if t.Percent <= 0 && t.Gas == 0 {
+		return true
+	}
+	return (t.Percent > 0 && c.Percent() > t.Percent) || (t.Gas > 0 && uint64(c.Delta()) > t.Gas)
+}
+
+// Change is a difference between the golden snapshot and a new one.
+type Change struct {
+	Test          string
+	Before, After uint64
+	// Added and Removed are set for tests in only one of the snapshots.
+	Added, Removed bool
+	// Regression is true if the gas rose past the thresholds.
+	Regression bool
+}
+
+// Delta is the change in gas.
+func (c Change) Delta() int64 {
+	return int64(c.After) - int64(c.Before)
+}
+
+// Percent is the change in gas as a percentage of the golden gas.
+func (c Change) Percent() float64 {
+	if c.Before == 0 {
+		return 0
+	}
+	return float64(c.Delta()) / float64(c.Before) * 100
+}
+
+// Compare returns the tests whose gas differs between the golden snapshot and
+// a new one. The largest rises come first, then added and removed tests.
+// Tests without gas are only reported if they were added or removed.
+func Compare(golden, snapshot []Entry, thresholds Thresholds) []Change {
+	before := map[string]Entry{}
+	for _, e := range golden {
+		before[e.Test] = e
+	}
+	var changes []Change
+	seen := map[string]bool{}
+	for _, e := range snapshot {
+		seen[e.Test] = true
+		old, ok := before[e.Test]
+		if !ok {
+			changes = append(changes, Change{Test: e.Test, After: e.Gas, Added: true})
+			continue
+		}
+		if !e.HasGas || !old.HasGas || e.Gas == old.Gas {
+			continue
+		}
+		c := Change{Test: e.Test, Before: old.Gas, After: e.Gas}
+		c.Regression = thresholds.exceeded(c)
+		changes = append(changes, c)
+	}
+	for _, e := range golden {
+		if !seen[e.Test] {
+			changes = append(changes, Change{Test: e.Test, Before: e.Gas, Removed: true})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if ra, rb := a.Added || a.Removed, b.Added || b.Removed; ra != rb {
+			return rb
+		}
+		if a.Percent() != b.Percent() {
+			return a.Percent() > b.Percent()
+		}
+		return a.Test < b.Test
+	})
+	return changes
+}
+
+// Regressions returns how many of the changes are regressions.
+func Regressions(changes []Change) int {
+	n := 0
+	for _, c := range changes {
+		if c.Regression {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteTable writes the changes as an aligned table.
+func WriteTable(w io.Writer, changes []Change) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "No gas changes.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEST\tBEFORE\tAFTER\tCHANGE\t")
+	for _, c := range changes {
+		switch {
+		case c.Added:
+			fmt.Fprintf(tw, "%s\t-\t%d\tadded\t\n", c.Test, c.After)
+		case c.Removed:
+			fmt.Fprintf(tw, "%s\t%d\t-\tremoved\t\n", c.Test, c.Before)
+		default:
+			change := fmt.Sprintf("%+d (%+.2f%%)", c.Delta(), c.Percent())
+			if c.Regression {
+				change += " REGRESSION"
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t\n", c.Test, c.Before, c.After, change)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,101 @@
+package gassnapshot
+
+import (
+	"strings"
+	"testing"
+)
+
+const golden = `CounterTest:test_Increment() (gas: 31303)
+CounterTest:test_SetNumber() (gas: 20000)
+CounterTest:testFuzz_SetNumber(uint256) (runs: 256, μ: 30000, ~: 31288)
+CounterTest:test_Removed() (gas: 100)
+InvariantTest:invariant_Total() (runs: 256, calls: 128000, reverts: 0)
+`
+
+const snapshot = `CounterTest:test_Increment() (gas: 31303)
+CounterTest:test_SetNumber() (gas: 20500)
+CounterTest:testFuzz_SetNumber(uint256) (runs: 256, μ: 30100, ~: 31288)
+CounterTest:test_Added() (gas: 5000)
+InvariantTest:invariant_Total() (runs: 256, calls: 128000, reverts: 2)
+`
+
+func parse(t *testing.T, s string) []Entry {
+	t.Helper()
+	entries, err := Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return entries
+}
+
+func TestParse(t *testing.T) {
+	entries := parse(t, golden)
+	if len(entries) != 5 {
+		t.Fatalf("expected 5 entries, got %d", len(entries))
+	}
+	if e := entries[2]; e.Test != "CounterTest:testFuzz_SetNumber(uint256)" || e.Gas != 30000 || !e.HasGas {
+		t.Errorf("expected the fuzz test's mean gas, got %+v", e)
+	}
+	if e := entries[4]; e.HasGas {
+		t.Errorf("expected no gas for an invariant test, got %+v", e)
+	}
+	if _, err := Parse(strings.NewReader("not a snapshot\n")); err == nil {
+		t.Error("expected an error for an invalid line")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name        string
+		thresholds  Thresholds
+		regressions []string
+	}{
+		{name: "any rise", regressions: []string{"CounterTest:test_SetNumber()", "CounterTest:testFuzz_SetNumber(uint256)"}},
+		// test_SetNumber rose 2.5%, the fuzz test 0.33%.
+		{name: "percent", thresholds: Thresholds{Percent: 1}, regressions: []string{"CounterTest:test_SetNumber()"}},
+		{name: "gas", thresholds: Thresholds{Gas: 500}, regressions: nil},
+		{name: "either", thresholds: Thresholds{Percent: 5, Gas: 50}, regressions: []string{"CounterTest:test_SetNumber()", "CounterTest:testFuzz_SetNumber(uint256)"}},
+	}
+	for _, test := range tests {
+		changes := Compare(parse(t, golden), parse(t, snapshot), test.thresholds)
+		var order, regressions []string
+		for _, c := range changes {
+			order = append(order, c.Test)
+			if c.Regression {
+				regressions = append(regressions, c.Test)
+			}
+		}
+		want := "CounterTest:test_SetNumber(),CounterTest:testFuzz_SetNumber(uint256),CounterTest:test_Added(),CounterTest:test_Removed()"
+		if got := strings.Join(order, ","); got != want {
+			t.Errorf("%s: expected changes %s, got %s", test.name, want, got)
+		}
+		if strings.Join(regressions, ",") != strings.Join(test.regressions, ",") {
+			t.Errorf("%s: expected regressions %v, got %v", test.name, test.regressions, regressions)
+		}
+		if Regressions(changes) != len(test.regressions) {
+			t.Errorf("%s: expected %d regressions, got %d", test.name, len(test.regressions), Regressions(changes))
+		}
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	var out strings.Builder
+	if err := WriteTable(&out, Compare(parse(t, golden), parse(t, snapshot), Thresholds{Percent: 1})); err != nil {
+		t.Fatalf("WriteTable failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "TEST") {
+		t.Fatalf("unexpected table:\n%s", out.String())
+	}
+	for i, want := range []string{"+500 (+2.50%) REGRESSION", "+100 (+0.33%)", "added", "removed"} {
+		if !strings.Contains(lines[i+1], want) {
+			t.Errorf("expected line %d to contain %q, got %q", i+1, want, lines[i+1])
+		}
+	}
+
+	out.Reset()
+	WriteTable(&out, nil)
+	if out.String() != "No gas changes.\n" {
+		t.Errorf("unexpected output for no changes: %q", out.String())
+	}
+}
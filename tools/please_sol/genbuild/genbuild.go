@@ -0,0 +1,209 @@
+// Package genbuild generates BUILD files for Solidity packages from their imports.
+//
+// Every .sol file in a package directory becomes a sol_contract, and every .t.sol
+// file a sol_test. Deps are inferred from each file's imports:
+//
+//   - Imports of files in the same package depend on that file's rule (":name").
+//   - Imports of files in other packages depend on the rule generated for that
+//     file in its own package ("//pkg:name").
+//   - Imports that resolve through a remapping depend on the sol_get target that
+//     wrote the remapping ("//third_party/solidity:forge-std").
+package genbuild
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"tools/please_sol/importgraph"
+	"tools/please_sol/solparse"
+)
+
+// DefaultSubinclude is the build_defs label subincluded at the top of generated files.
+const DefaultSubinclude = "///solidity//build_defs:solidity"
+
+// Rule is a single generated build rule.
+type Rule struct {
+	Kind string // "sol_contract" or "sol_test"
+	Name string
+	Src  string
+	Deps []string
+}
+
+// Generator infers build rules for a package directory.
+type Generator struct {
+	resolver    *importgraph.Resolver
+	solcVersion string
+	subinclude  string
+}
+
+// New creates a new Generator. solcVersion is added to every rule if non-empty.
+func New(remappings []importgraph.Remapping, solcVersion, subinclude string) *Generator {
+	if subinclude == "" {
+		subinclude = DefaultSubinclude
+	}
+	return &Generator{
+		resolver:    importgraph.NewResolver(remappings),
+		solcVersion: solcVersion,
+		subinclude:  subinclude,
+	}
+}
+
+// Rules returns the rules for the Solidity files directly in dir, which should be
+// relative to the repo root. Rules are sorted by source file.
+func (g *Generator) Rules(dir string) ([]Rule, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	pkg := path.Clean(filepath.ToSlash(dir))
+	var rules []Rule
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sol") {
+			continue
+		}
+		file := path.Join(pkg, entry.Name())
+		unit, err := solparse.ParseFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		rule := Rule{Kind: "sol_contract", Name: RuleName(entry.Name()), Src: entry.Name()}
+		if IsTest(entry.Name()) {
+			rule.Kind = "sol_test"
+		}
+
+		deps := map[string]bool{}
+		for _, imp := range unit.Imports {
+			resolved, rm := g.resolver.Resolve(file, imp.Path)
+			var dep string
+			if rm != nil {
+				dep = rm.Label()
+			} else {
+				dep = fileLabel(pkg, resolved)
+			}
+			if dep != "" && dep != ":"+rule.Name {
+				deps[dep] = true
+			}
+		}
+		for dep := range deps {
+			rule.Deps = append(rule.Deps, dep)
+		}
+		sortDeps(rule.Deps)
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Write writes a complete BUILD file containing the given rules.
+func (g *Generator) Write(w io.Writer, rules []Rule) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "subinclude(%q)\n", g.subinclude)
+	for _, rule := range rules {
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "%s(\n", rule.Kind)
+		fmt.Fprintf(&b, "    name = %q,\n", rule.Name)
+		fmt.Fprintf(&b, "    src = %q,\n", rule.Src)
+		if g.solcVersion != "" {
+			fmt.Fprintf(&b, "    solc_version = %q,\n", g.solcVersion)
+		}
+		if len(rule.Deps) > 0 {
+			b.WriteString(formatList("deps", rule.Deps))
+		}
+		if rule.Kind == "sol_contract" {
+			b.WriteString("    languages = [],\n")
+			b.WriteString("    visibility = [\"PUBLIC\"],\n")
+		}
+		b.WriteString(")\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatList formats a list attribute, on one line if it fits within 80 columns.
+func formatList(attr string, items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = fmt.Sprintf("%q", item)
+	}
+	line := fmt.Sprintf("    %s = [%s],\n", attr, strings.Join(quoted, ", "))
+	if len(line) <= 81 {
+		return line
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "    %s = [\n", attr)
+	for _, q := range quoted {
+		fmt.Fprintf(&b, "        %s,\n", q)
+	}
+	b.WriteString("    ],\n")
+	return b.String()
+}
+
+// IsTest returns true if the file is a forge test file.
+func IsTest(file string) bool {
+	return strings.HasSuffix(file, ".t.sol")
+}
+
+// RuleName derives a rule name from a Solidity file name, e.g.
+// "OzERC20.sol" -> "oz_erc20" and "Counter.t.sol" -> "counter_test".
+func RuleName(file string) string {
+	base := path.Base(file)
+	test := IsTest(base)
+	base = strings.TrimSuffix(strings.TrimSuffix(base, ".t.sol"), ".sol")
+
+	name := snakeCase(base)
+	if test && !strings.HasSuffix(name, "_test") {
+		name += "_test"
+	}
+	return name
+}
+
+// snakeCase converts a CamelCase identifier to snake_case, keeping acronyms together.
+func snakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		if r == '-' || r == '.' {
+			r = '_'
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// fileLabel returns the label of the rule generated for a Solidity file, relative to pkg.
+func fileLabel(pkg, file string) string {
+	dir, base := path.Split(file)
+	dir = strings.TrimSuffix(dir, "/")
+	if !strings.HasSuffix(base, ".sol") {
+		return ""
+	}
+	if dir == pkg {
+		return ":" + RuleName(base)
+	}
+	return "//" + dir + ":" + RuleName(base)
+}
+
+// sortDeps sorts local labels (":x") before absolute ones ("//x:y").
+func sortDeps(deps []string) {
+	sort.Slice(deps, func(i, j int) bool {
+		li, lj := strings.HasPrefix(deps[i], ":"), strings.HasPrefix(deps[j], ":")
+		if li != lj {
+			return li
+		}
+		return deps[i] < deps[j]
+	})
+}
@@ -0,0 +1,128 @@
+package genbuild
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tools/please_sol/importgraph"
+)
+
+func TestRuleName(t *testing.T) {
+	tests := map[string]string{
+		"Counter.sol":        "counter",
+		"Counter.t.sol":      "counter_test",
+		"OzERC20.sol":        "oz_erc20",
+		"OzERC721.t.sol":     "oz_erc721_test",
+		"SimpleStorage.sol":  "simple_storage",
+		"ForgeStdTest.t.sol": "forge_std_test",
+		"ERC20.sol":          "erc20",
+		"my-lib.sol":         "my_lib",
+	}
+	for file, want := range tests {
+		if got := RuleName(file); got != want {
+			t.Errorf("%s: expected %q, got %q", file, want, got)
+		}
+	}
+}
+
+// chdirTemp creates the given files under a temporary directory and changes into it,
+// so that package paths are relative to a fake repo root.
+func chdirTemp(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	t.Chdir(dir)
+}
+
+func TestRules(t *testing.T) {
+	chdirTemp(t, map[string]string{
+		"test/tokens/OzERC20.sol":   `import "@openzeppelin/contracts/token/ERC20/ERC20.sol";`,
+		"test/tokens/OzERC20.t.sol": "import \"forge-std/Test.sol\";\nimport \"./OzERC20.sol\";\nimport \"../Counter.sol\";",
+		"test/tokens/README.md":     "not solidity",
+		"test/Counter.sol":          "contract Counter {}",
+	})
+
+	g := New([]importgraph.Remapping{
+		{Prefix: "@openzeppelin/contracts/", Target: "test/openzeppelin-contracts/"},
+		{Prefix: "forge-std/", Target: "test/forge-std/"},
+	}, "", "")
+
+	rules, err := g.Rules("test/tokens")
+	if err != nil {
+		t.Fatalf("Rules failed: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d: %+v", len(rules), rules)
+	}
+
+	contract := rules[0]
+	if contract.Kind != "sol_contract" || contract.Name != "oz_erc20" || contract.Src != "OzERC20.sol" {
+		t.Errorf("unexpected contract rule: %+v", contract)
+	}
+	if strings.Join(contract.Deps, ",") != "//test:openzeppelin-contracts" {
+		t.Errorf("unexpected contract deps: %v", contract.Deps)
+	}
+
+	test := rules[1]
+	if test.Kind != "sol_test" || test.Name != "oz_erc20_test" {
+		t.Errorf("unexpected test rule: %+v", test)
+	}
+	if strings.Join(test.Deps, ",") != ":oz_erc20,//test:counter,//test:forge-std" {
+		t.Errorf("unexpected test deps: %v", test.Deps)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	g := New(nil, "0.8.20", "//build_defs:solidity")
+	rules := []Rule{
+		{Kind: "sol_contract", Name: "helper", Src: "Helper.sol"},
+		{Kind: "sol_test", Name: "multi_test", Src: "Multi.t.sol", Deps: []string{
+			":helper",
+			"//test:forge-std",
+			"//test:openzeppelin-contracts",
+			"//test:solmate",
+		}},
+	}
+
+	var b strings.Builder
+	if err := g.Write(&b, rules); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	want := `subinclude("//build_defs:solidity")
+
+sol_contract(
+    name = "helper",
+    src = "Helper.sol",
+    solc_version = "0.8.20",
+    languages = [],
+    visibility = ["PUBLIC"],
+)
+
+sol_test(
+    name = "multi_test",
+    src = "Multi.t.sol",
+    solc_version = "0.8.20",
+    deps = [
+        ":helper",
+        "//test:forge-std",
+        "//test:openzeppelin-contracts",
+        "//test:solmate",
+    ],
+)
+`
+	if b.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,97 @@
+// Package ideconfig generates a foundry.toml and remappings.txt for the repo root,
+// so that editors and a plain `forge` resolve imports the same way the build does.
+//
+// Remappings written by sol_get point at package paths (e.g. "third_party/solidity/forge-std/"),
+// which only exist inside a build. They are rewritten to point at the built
+// outputs under plz-out/gen instead.
+package ideconfig
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"tools/please_sol/foundrytoml"
+	"tools/please_sol/importgraph"
+)
+
+// Options are the compiler settings to write to foundry.toml.
+type Options struct {
+	SolcVersion   string
+	EvmVersion    string
+	Optimizer     bool
+	OptimizerRuns int
+	// Src is the directory forge compiles by default. Left unset if empty.
+	Src string
+}
+
+// Config returns the foundry.toml for the given options. Build outputs and the
+// forge cache go under plz-out so that running forge doesn't litter the repo.
+func Config(opts Options) *foundrytoml.Config {
+	config := &foundrytoml.Config{
+		Profile: map[string]foundrytoml.Profile{
+			foundrytoml.DefaultProfile: {
+				SolcVersion:   opts.SolcVersion,
+				EvmVersion:    opts.EvmVersion,
+				Optimizer:     opts.Optimizer,
+				OptimizerRuns: opts.OptimizerRuns,
+				Src:           opts.Src,
+				Out:           "plz-out/forge/out",
+				CachePath:     "plz-out/forge/cache",
+			},
+		},
+	}
+	// Always write the optimizer setting, since forge's default may not match the build's.
+	config.MarkSet(foundrytoml.DefaultProfile, "optimizer")
+	return config
+}
+
+// Remappings rewrites remappings from .remapping files to point at genDir, and
+// returns them sorted with duplicates removed.
+func Remappings(remappings []importgraph.Remapping, genDir string) []string {
+	if genDir == "" {
+		genDir = importgraph.DefaultGenDir
+	}
+	seen := map[string]bool{}
+	var lines []string
+	for _, r := range remappings {
+		if !path.IsAbs(r.Target) && !strings.HasPrefix(r.Target, genDir+"/") {
+			target := path.Join(genDir, r.Target)
+			if strings.HasSuffix(r.Target, "/") {
+				target += "/"
+			}
+			r.Target = target
+		}
+		if line := r.String(); !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// Write writes foundry.toml and remappings.txt into dir.
+func Write(dir string, config *foundrytoml.Config, remappings []string) error {
+	data, err := config.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to encode foundry.toml: %w", err)
+	}
+	header := "# Generated by please_sol ide-config. Do not edit; regenerate instead.\n"
+	if err := os.WriteFile(filepath.Join(dir, "foundry.toml"), append([]byte(header), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write foundry.toml: %w", err)
+	}
+
+	var b strings.Builder
+	for _, line := range remappings {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "remappings.txt"), []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write remappings.txt: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package ideconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tools/please_sol/foundrytoml"
+	"tools/please_sol/importgraph"
+)
+
+func TestRemappings(t *testing.T) {
+	got := Remappings([]importgraph.Remapping{
+		{Prefix: "forge-std/", Target: "third_party/solidity/forge-std/"},
+		{Prefix: "@openzeppelin/contracts/", Target: "third_party/solidity/openzeppelin-contracts/"},
+		{Prefix: "forge-std/", Target: "third_party/solidity/forge-std/"},
+		{Context: "test", Prefix: "lib/", Target: "plz-out/gen/lib"},
+		{Prefix: "abs/", Target: "/opt/abs/"},
+	}, "")
+
+	want := []string{
+		"@openzeppelin/contracts/=plz-out/gen/third_party/solidity/openzeppelin-contracts/",
+		"abs/=/opt/abs/",
+		"forge-std/=plz-out/gen/third_party/solidity/forge-std/",
+		"test:lib/=plz-out/gen/lib",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	config := Config(Options{SolcVersion: "0.8.20", EvmVersion: "paris", Optimizer: true, OptimizerRuns: 100})
+	if err := Write(dir, config, []string{"forge-std/=plz-out/gen/third_party/solidity/forge-std/"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	parsed, err := foundrytoml.ParseFile(filepath.Join(dir, "foundry.toml"))
+	if err != nil {
+		t.Fatalf("generated foundry.toml doesn't parse: %v", err)
+	}
+	p := parsed.ResolveProfile("")
+	if p.SolcVersion != "0.8.20" || p.EvmVersion != "paris" || !p.Optimizer || p.OptimizerRuns != 100 {
+		t.Errorf("unexpected profile: %+v", p)
+	}
+	if p.Src != "" || p.Out != "plz-out/forge/out" {
+		t.Errorf("unexpected paths: src %q, out %q", p.Src, p.Out)
+	}
+
+	unknown, err := foundrytoml.CheckFile(filepath.Join(dir, "foundry.toml"))
+	if err != nil || len(unknown) != 0 {
+		t.Errorf("expected no unknown keys, got %v (%v)", unknown, err)
+	}
+
+	// A disabled optimizer is written explicitly.
+	data, err := Config(Options{SolcVersion: "0.8.20"}).Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), "optimizer = false") {
+		t.Errorf("expected optimizer = false in:\n%s", data)
+	}
+
+	remappings, err := os.ReadFile(filepath.Join(dir, "remappings.txt"))
+	if err != nil {
+		t.Fatalf("failed to read remappings.txt: %v", err)
+	}
+	if string(remappings) != "forge-std/=plz-out/gen/third_party/solidity/forge-std/\n" {
+		t.Errorf("unexpected remappings.txt: %q", remappings)
+	}
+}
@@ -0,0 +1,198 @@
+// Package importgraph resolves the imports of Solidity sources into a file-level dependency graph.
+//
+// Imports are resolved the same way forge resolves them: relative imports ("./", "../")
+// against the importing file's directory, and everything else against a set of
+// remappings in "prefix=target" form (the format written to .remapping files by
+// sol_get). As in solc, when several remappings match, the one with the longest
+// context wins, then the one with the longest prefix.
+package importgraph
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"tools/please_sol/solparse"
+)
+
+// DefaultGenDir is where Please writes the outputs of sol_get rules, which the
+// package-path targets of their remappings are relative to.
+const DefaultGenDir = "plz-out/gen"
+
+// Remapping is a single forge remapping of the form "[context:]prefix=target".
+type Remapping struct {
+	Context string
+	Prefix  string
+	Target  string
+}
+
+// String formats the remapping in forge's "[context:]prefix=target" syntax.
+func (r Remapping) String() string {
+	if r.Context != "" {
+		return r.Context + ":" + r.Prefix + "=" + r.Target
+	}
+	return r.Prefix + "=" + r.Target
+}
+
+// Label returns the build label of the target that provides this remapping.
+//
+// sol_get writes remappings of the form "prefix=pkg/name/", so the providing
+// target is //pkg:name.
+func (r Remapping) Label() string {
+	target := strings.TrimSuffix(path.Clean(r.Target), "/")
+	dir, name := path.Split(target)
+	return "//" + strings.TrimSuffix(dir, "/") + ":" + name
+}
+
+// ParseRemapping parses a single "[context:]prefix=target" line.
+func ParseRemapping(line string) (Remapping, error) {
+	line = strings.TrimSpace(line)
+	lhs, target, ok := strings.Cut(line, "=")
+	if !ok || lhs == "" {
+		return Remapping{}, fmt.Errorf("invalid remapping %q: expected prefix=target", line)
+	}
+	var r Remapping
+	if context, prefix, ok := strings.Cut(lhs, ":"); ok {
+		r.Context = context
+		lhs = prefix
+	}
+	r.Prefix = lhs
+	r.Target = target
+	return r, nil
+}
+
+// LoadRemappingFiles reads remappings from the given files, one per line.
+// Blank lines are ignored.
+func LoadRemappingFiles(paths []string) ([]Remapping, error) {
+	var remappings []Remapping
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open remapping file: %w", err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			r, err := ParseRemapping(line)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s: %w", p, err)
+			}
+			remappings = append(remappings, r)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+	}
+	return remappings, nil
+}
+
+// FindRemappingFiles returns every .remapping file under dir, sorted by path.
+func FindRemappingFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".remapping") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s for remappings: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Edge is a single resolved import.
+type Edge struct {
+	solparse.Import
+	Resolved  string `json:"resolved"`
+	Remapping string `json:"remapping,omitempty"`
+}
+
+// Graph maps each source file to its resolved imports.
+type Graph struct {
+	Files map[string][]Edge `json:"files"`
+}
+
+// Resolver resolves import paths against a set of remappings.
+type Resolver struct {
+	remappings []Remapping
+}
+
+// NewResolver creates a new Resolver with the given remappings.
+func NewResolver(remappings []Remapping) *Resolver {
+	return &Resolver{remappings: remappings}
+}
+
+// Resolve resolves an import made from the file at from. It returns the resolved
+// path and the remapping that supplied it, if any.
+func (r *Resolver) Resolve(from, importPath string) (string, *Remapping) {
+	if strings.HasPrefix(importPath, "./") || strings.HasPrefix(importPath, "../") {
+		return path.Join(path.Dir(filepath.ToSlash(from)), importPath), nil
+	}
+
+	var best *Remapping
+	for i, rm := range r.remappings {
+		if !strings.HasPrefix(importPath, rm.Prefix) {
+			continue
+		}
+		if rm.Context != "" && !strings.HasPrefix(filepath.ToSlash(from), rm.Context) {
+			continue
+		}
+		if best == nil || len(rm.Context) > len(best.Context) ||
+			(len(rm.Context) == len(best.Context) && len(rm.Prefix) > len(best.Prefix)) {
+			best = &r.remappings[i]
+		}
+	}
+	if best == nil {
+		return path.Clean(importPath), nil
+	}
+	return path.Clean(best.Target + strings.TrimPrefix(importPath, best.Prefix)), best
+}
+
+// Build parses every Solidity file under the given paths and resolves their imports.
+func (r *Resolver) Build(paths []string) (*Graph, error) {
+	files, err := solparse.FindSources(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &Graph{Files: map[string][]Edge{}}
+	for _, file := range files {
+		unit, err := solparse.ParseFile(file)
+		if err != nil {
+			return nil, err
+		}
+		edges := []Edge{}
+		for _, imp := range unit.Imports {
+			resolved, rm := r.Resolve(file, imp.Path)
+			edge := Edge{Import: imp, Resolved: resolved}
+			if rm != nil {
+				edge.Remapping = rm.String()
+			}
+			edges = append(edges, edge)
+		}
+		graph.Files[filepath.ToSlash(file)] = edges
+	}
+	return graph, nil
+}
+
+// ToJSON converts the graph to indented JSON.
+func (g *Graph) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
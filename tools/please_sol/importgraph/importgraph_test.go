@@ -0,0 +1,226 @@
+package importgraph
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRemapping(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    Remapping
+		wantErr bool
+	}{
+		{
+			line: "@openzeppelin/contracts/=third_party/openzeppelin-contracts/",
+			want: Remapping{Prefix: "@openzeppelin/contracts/", Target: "third_party/openzeppelin-contracts/"},
+		},
+		{
+			line: "  forge-std/=test/forge-std/\n",
+			want: Remapping{Prefix: "forge-std/", Target: "test/forge-std/"},
+		},
+		{
+			line: "src/legacy:@oz/=lib/oz-v4/",
+			want: Remapping{Context: "src/legacy", Prefix: "@oz/", Target: "lib/oz-v4/"},
+		},
+		{line: "no-equals-sign", wantErr: true},
+		{line: "=target/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := ParseRemapping(tt.line)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRemapping failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	r := NewResolver([]Remapping{
+		{Prefix: "@openzeppelin/", Target: "lib/oz-all/"},
+		{Prefix: "@openzeppelin/contracts/", Target: "third_party/oz/"},
+		{Prefix: "forge-std/", Target: "test/forge-std/"},
+		{Context: "legacy/", Prefix: "forge-std/", Target: "legacy/forge-std/"},
+	})
+
+	tests := []struct {
+		name          string
+		from          string
+		importPath    string
+		wantResolved  string
+		wantRemapping string
+	}{
+		{
+			name:         "relative same dir",
+			from:         "test/02_imports/LocalImport.sol",
+			importPath:   "./Helper.sol",
+			wantResolved: "test/02_imports/Helper.sol",
+		},
+		{
+			name:         "relative parent dir",
+			from:         "test/02_imports/LocalImport.sol",
+			importPath:   "../Counter.sol",
+			wantResolved: "test/Counter.sol",
+		},
+		{
+			name:          "longest prefix wins",
+			from:          "src/Token.sol",
+			importPath:    "@openzeppelin/contracts/token/ERC20/ERC20.sol",
+			wantResolved:  "third_party/oz/token/ERC20/ERC20.sol",
+			wantRemapping: "@openzeppelin/contracts/=third_party/oz/",
+		},
+		{
+			name:          "context applies within directory",
+			from:          "legacy/Old.sol",
+			importPath:    "forge-std/Test.sol",
+			wantResolved:  "legacy/forge-std/Test.sol",
+			wantRemapping: "legacy/:forge-std/=legacy/forge-std/",
+		},
+		{
+			name:          "context ignored outside directory",
+			from:          "src/New.sol",
+			importPath:    "forge-std/Test.sol",
+			wantResolved:  "test/forge-std/Test.sol",
+			wantRemapping: "forge-std/=test/forge-std/",
+		},
+		{
+			name:         "unmapped import resolves from root",
+			from:         "src/A.sol",
+			importPath:   "src/lib/B.sol",
+			wantResolved: "src/lib/B.sol",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, rm := r.Resolve(tt.from, tt.importPath)
+			if resolved != tt.wantResolved {
+				t.Errorf("expected resolved %q, got %q", tt.wantResolved, resolved)
+			}
+			gotRemapping := ""
+			if rm != nil {
+				gotRemapping = rm.String()
+			}
+			if gotRemapping != tt.wantRemapping {
+				t.Errorf("expected remapping %q, got %q", tt.wantRemapping, gotRemapping)
+			}
+		})
+	}
+}
+
+func TestLoadRemappingFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "forge-std.remapping")
+	if err := os.WriteFile(path, []byte("forge-std/=test/forge-std/\n\n"), 0644); err != nil {
+		t.Fatalf("failed to write remapping file: %v", err)
+	}
+
+	remappings, err := LoadRemappingFiles([]string{path})
+	if err != nil {
+		t.Fatalf("LoadRemappingFiles failed: %v", err)
+	}
+	if len(remappings) != 1 || remappings[0].Prefix != "forge-std/" {
+		t.Errorf("unexpected remappings: %+v", remappings)
+	}
+
+	if _, err := LoadRemappingFiles([]string{filepath.Join(dir, "missing.remapping")}); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"src/Token.sol":  "import {ERC20} from \"solmate/tokens/ERC20.sol\";\nimport \"./Helper.sol\";\ncontract Token {}",
+		"src/Helper.sol": "library Helper {}",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	r := NewResolver([]Remapping{{Prefix: "solmate/", Target: "third_party/solmate/"}})
+	graph, err := r.Build([]string{dir})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	helper := filepath.ToSlash(filepath.Join(dir, "src/Helper.sol"))
+	if edges, ok := graph.Files[helper]; !ok || len(edges) != 0 {
+		t.Errorf("expected Helper.sol with no imports, got %v", edges)
+	}
+
+	edges := graph.Files[filepath.ToSlash(filepath.Join(dir, "src/Token.sol"))]
+	if len(edges) != 2 {
+		t.Fatalf("expected 2 imports, got %+v", edges)
+	}
+	if edges[0].Resolved != "third_party/solmate/tokens/ERC20.sol" || edges[0].Remapping != "solmate/=third_party/solmate/" {
+		t.Errorf("unexpected solmate edge: %+v", edges[0])
+	}
+	if len(edges[0].Symbols) != 1 || edges[0].Symbols[0].Name != "ERC20" {
+		t.Errorf("expected ERC20 symbol, got %+v", edges[0].Symbols)
+	}
+	if edges[1].Resolved != helper {
+		t.Errorf("expected %q, got %q", helper, edges[1].Resolved)
+	}
+
+	if _, err := graph.ToJSON(); err != nil {
+		t.Errorf("ToJSON failed: %v", err)
+	}
+}
+
+func TestRemappingLabel(t *testing.T) {
+	tests := map[string]string{
+		"forge-std/=test/forge-std/":                                  "//test:forge-std",
+		"@openzeppelin/contracts/=third_party/solidity/openzeppelin/": "//third_party/solidity:openzeppelin",
+		"solady/=solady": "//:solady",
+	}
+	for line, want := range tests {
+		r, err := ParseRemapping(line)
+		if err != nil {
+			t.Fatalf("ParseRemapping failed: %v", err)
+		}
+		if got := r.Label(); got != want {
+			t.Errorf("%s: expected %q, got %q", line, want, got)
+		}
+	}
+}
+
+func TestFindRemappingFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b/solmate.remapping", "a/forge-std.remapping", "a/forge-std.sol"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	files, err := FindRemappingFiles(dir)
+	if err != nil {
+		t.Fatalf("FindRemappingFiles failed: %v", err)
+	}
+	want := []string{filepath.Join(dir, "a/forge-std.remapping"), filepath.Join(dir, "b/solmate.remapping")}
+	if len(files) != len(want) || files[0] != want[0] || files[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, files)
+	}
+}
@@ -0,0 +1,73 @@
+// Package listcontracts discovers the contracts, interfaces and libraries declared in Solidity sources.
+//
+// The output is used by sol_contract to decide which compiled artifacts to generate
+// language bindings for. Entries are named "File.sol/Name", matching the layout of
+// forge's out/ directory.
+package listcontracts
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"tools/please_sol/solparse"
+)
+
+// Entry is a single declaration found in a source file.
+type Entry struct {
+	File string // Base name of the source file, e.g. "Counter.sol"
+	Path string // Path to the source file as found on disk
+	Name string
+	Kind string
+}
+
+// Artifact returns the "File.sol/Name" path used by forge for this declaration's artifacts.
+func (e Entry) Artifact() string {
+	return e.File + "/" + e.Name
+}
+
+// List parses every Solidity file under the given paths and returns their declarations.
+// Directories are walked recursively. The result is sorted by file then name, and
+// duplicate file/name pairs are reported once.
+func List(paths []string) ([]Entry, error) {
+	files, err := solparse.FindSources(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var entries []Entry
+	for _, file := range files {
+		unit, err := solparse.ParseFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range unit.Contracts {
+			entry := Entry{File: filepath.Base(file), Path: file, Name: c.Name, Kind: c.Kind}
+			if seen[entry.Artifact()] {
+				continue
+			}
+			seen[entry.Artifact()] = true
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].File != entries[j].File {
+			return entries[i].File < entries[j].File
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// Write writes entries one per line as "File.sol/Name<TAB>kind".
+func Write(w io.Writer, entries []Entry) error {
+	for _, e := range entries {
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", e.Artifact(), e.Kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package listcontracts
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles creates the given files under a temporary directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestList_Directory(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"token/ERC20.sol":       "abstract contract ERC20 {}\ninterface IERC20Errors {}",
+		"access/Ownable.sol":    "abstract contract Ownable {}",
+		"utils/Math.sol":        "library Math {}",
+		"interfaces/IERC20.sol": "interface IERC20 {}",
+		"README.md":             "contract NotSolidity {}",
+	})
+
+	entries, err := List([]string{dir})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, entries); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	want := "ERC20.sol/ERC20\tabstract\n" +
+		"ERC20.sol/IERC20Errors\tinterface\n" +
+		"IERC20.sol/IERC20\tinterface\n" +
+		"Math.sol/Math\tlibrary\n" +
+		"Ownable.sol/Ownable\tabstract\n"
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestList_Deduplicates(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a/IERC20.sol": "interface IERC20 {}",
+		"b/IERC20.sol": "interface IERC20 {}",
+	})
+
+	entries, err := List([]string{dir})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected 1 entry, got %d: %v", len(entries), entries)
+	}
+}
+
+func TestList_SingleFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"Counter.sol": "contract Counter {\n    uint256 public number;\n}",
+	})
+
+	entries, err := List([]string{filepath.Join(dir, "Counter.sol")})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Artifact() != "Counter.sol/Counter" {
+		t.Errorf("expected Counter.sol/Counter, got %v", entries)
+	}
+}
+
+func TestList_MissingPath(t *testing.T) {
+	if _, err := List([]string{"/nonexistent/Foo.sol"}); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
@@ -0,0 +1,317 @@
+// Package listtests finds the test functions in compiled test contracts and
+// turns a selection of them into forge test's filters, so that a large test
+// contract can be split into shards or narrowed to a few tests.
+package listtests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Test is a test function in a contract.
+type Test struct {
+	Contract string
+	Name     string
+}
+
+// String returns the test as "Contract.name", the form Select and FilterArgs take.
+func (t Test) String() string {
+	return t.Contract + "." + t.Name
+}
+
+// testPrefixes are the prefixes of the functions forge runs as tests.
+var testPrefixes = []string{"test", "invariant", "statefulFuzz"}
+
+// artifact is the part of a forge artifact used here.
+type artifact struct {
+	ABI []struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	} `json:"abi"`
+	// Bytecode is {"object": "0x..."}, or the hex itself in older artifacts.
+	Bytecode json.RawMessage `json:"bytecode"`
+}
+
+// deployable returns true if the artifact has creation code, i.e. isn't for an
+// abstract contract or an interface, whose tests forge doesn't run.
+func (a *artifact) deployable() bool {
+	var code string
+	if json.Unmarshal(a.Bytecode, &code) != nil {
+		var object struct {
+			Object string `json:"object"`
+		}
+		json.Unmarshal(a.Bytecode, &object)
+		code = object.Object
+	}
+	return strings.TrimPrefix(code, "0x") != ""
+}
+
+// List returns the test functions in forge artifacts, given as artifact files
+// or directories such as out/ to search. The result is sorted by contract then
+// name.
+func List(paths []string) ([]Test, error) {
+	seen := map[Test]bool{}
+	var tests []Test
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == "build-info" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(path) != ".json" {
+				return nil
+			}
+			found, err := listArtifact(path)
+			if err != nil {
+				return err
+			}
+			for _, t := range found {
+				if !seen[t] {
+					seen[t] = true
+					tests = append(tests, t)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tests: %w", err)
+		}
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		if tests[i].Contract != tests[j].Contract {
+			return tests[i].Contract < tests[j].Contract
+		}
+		return tests[i].Name < tests[j].Name
+	})
+	return tests, nil
+}
+
+// listArtifact returns the tests in one artifact. The contract's name is the
+// file's, without any solc version forge added, e.g. "Counter.0.8.20.json".
+func listArtifact(path string) ([]Test, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var a artifact
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if !a.deployable() {
+		return nil, nil
+	}
+	contract, _, _ := strings.Cut(filepath.Base(path), ".")
+	var tests []Test
+	for _, entry := range a.ABI {
+		if entry.Type == "function" && isTest(entry.Name) {
+			tests = append(tests, Test{Contract: contract, Name: entry.Name})
+		}
+	}
+	return tests, nil
+}
+
+func isTest(name string) bool {
+	for _, prefix := range testPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseShard parses a shard given as "INDEX/COUNT", e.g. "0/4".
+func ParseShard(s string) (index, count int, err error) {
+	i, n, ok := strings.Cut(s, "/")
+	index, err1 := strconv.Atoi(i)
+	count, err2 := strconv.Atoi(n)
+	if !ok || err1 != nil || err2 != nil || count < 1 || index < 0 || index >= count {
+		return 0, 0, fmt.Errorf("invalid shard %q; expected INDEX/COUNT with 0 <= INDEX < COUNT", s)
+	}
+	return index, count, nil
+}
+
+// Shard returns the tests in shard index of count. forge matches contracts and
+// test names separately (see FilterArgs), so a shard can only hold whole
+// contracts, or part of a single contract, without also running tests from
+// another shard. Contracts are shared out whole, biggest first, while there are
+// at least as many as shards; otherwise each gets shards in proportion to its
+// tests and its tests are dealt out between them.
+func Shard(tests []Test, index, count int) []Test {
+	var contracts [][]Test
+	position := map[string]int{}
+	for _, t := range tests {
+		i, ok := position[t.Contract]
+		if !ok {
+			i = len(contracts)
+			position[t.Contract] = i
+			contracts = append(contracts, nil)
+		}
+		contracts[i] = append(contracts[i], t)
+	}
+
+	if len(contracts) >= count {
+		order := make([]int, len(contracts))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool { return len(contracts[order[a]]) > len(contracts[order[b]]) })
+		assigned := make([]int, len(contracts))
+		load := make([]int, count)
+		for _, c := range order {
+			least := 0
+			for i := range load {
+				if load[i] < load[least] {
+					least = i
+				}
+			}
+			assigned[c] = least
+			load[least] += len(contracts[c])
+		}
+		var shard []Test
+		for c, tests := range contracts {
+			if assigned[c] == index {
+				shard = append(shard, tests...)
+			}
+		}
+		return shard
+	}
+
+	// Give each extra shard to the contract with the most tests per shard, as long
+	// as it has tests to spare. Any shards left over are empty.
+	shards := make([]int, len(contracts))
+	for i := range shards {
+		shards[i] = 1
+	}
+	for extra := count - len(contracts); extra > 0; extra-- {
+		busiest := -1
+		for c, n := range shards {
+			if n < len(contracts[c]) && (busiest < 0 || len(contracts[c])*shards[busiest] > len(contracts[busiest])*n) {
+				busiest = c
+			}
+		}
+		if busiest < 0 {
+			break
+		}
+		shards[busiest]++
+	}
+	first := 0
+	for c, n := range shards {
+		if index < first+n {
+			var shard []Test
+			for i, t := range contracts[c] {
+				if i%n == index-first {
+					shard = append(shard, t)
+				}
+			}
+			return shard
+		}
+		first += n
+	}
+	return nil
+}
+
+// Select returns the tests matching any of the selectors, each of which is a
+// test function's name or "Contract.name".
+func Select(tests []Test, selectors []string) []Test {
+	var selected []Test
+	for _, t := range tests {
+		for _, s := range selectors {
+			if s == t.Name || s == t.String() {
+				selected = append(selected, t)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// FilterArgs returns the forge test arguments that run the tests matching the
+// selectors, as for Select. forge matches contracts and functions separately, so
+// selecting A.x and B.y also runs A.y and B.x if they exist.
+func FilterArgs(selectors []string) []string {
+	if len(selectors) == 0 {
+		return nil
+	}
+	contracts := map[string]bool{}
+	names := map[string]bool{}
+	anyContract := false
+	for _, s := range selectors {
+		contract, name, ok := strings.Cut(s, ".")
+		if !ok {
+			name, anyContract = s, true
+		} else {
+			contracts[regexp.QuoteMeta(contract)] = true
+		}
+		names[regexp.QuoteMeta(name)] = true
+	}
+	args := []string{"--match-test", alternatives(names)}
+	if !anyContract {
+		args = append(args, "--match-contract", alternatives(contracts))
+	}
+	return args
+}
+
+// alternatives returns a regex matching exactly any of the given patterns.
+func alternatives(patterns map[string]bool) string {
+	var sorted []string
+	for p := range patterns {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+	return "^(" + strings.Join(sorted, "|") + ")$"
+}
+
+// SplitArgs splits a test's arguments, e.g. $TEST_ARGS, at whitespace into the
+// tests named before any flag and the rest, which are left for forge.
+func SplitArgs(args string) (tests, rest []string) {
+	fields := strings.Fields(args)
+	for len(fields) > 0 && !strings.HasPrefix(fields[0], "-") {
+		tests, fields = append(tests, fields[0]), fields[1:]
+	}
+	if len(fields) == 0 {
+		return tests, nil
+	}
+	return tests, fields
+}
+
+// Narrow returns the forge test arguments that run the tests matching the
+// selectors, as for Select, in shard "INDEX/COUNT" of the tests in artifacts, or
+// in all of them if shard is "". ok is false if the shard has no such tests, in
+// which case forge shouldn't be run, since it fails if no tests match.
+func Narrow(selectors []string, shard, artifacts string) (args []string, ok bool, err error) {
+	if shard == "" {
+		return FilterArgs(selectors), true, nil
+	}
+	index, count, err := ParseShard(shard)
+	if err != nil {
+		return nil, false, err
+	}
+	tests, err := List([]string{artifacts})
+	if err != nil {
+		return nil, false, err
+	}
+	tests = Shard(tests, index, count)
+	if len(selectors) > 0 {
+		tests = Select(tests, selectors)
+	}
+	if len(tests) == 0 {
+		return nil, false, nil
+	}
+	selectors = nil
+	for _, t := range tests {
+		selectors = append(selectors, t.String())
+	}
+	return FilterArgs(selectors), true, nil
+}
@@ -0,0 +1,194 @@
+package listtests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// writeArtifacts lays out forge artifacts like out/ under a temporary directory.
+func writeArtifacts(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Counter.t.sol/CounterTest.json": `{"abi":[
+			{"type":"function","name":"setUp"},
+			{"type":"function","name":"test_Increment"},
+			{"type":"function","name":"testFuzz_SetNumber"},
+			{"type":"function","name":"invariant_Total"},
+			{"type":"event","name":"testEvent"}
+		],"bytecode":{"object":"0x6080"}}`,
+		"Counter.t.sol/CounterTest.0.8.19.json": `{"abi":[{"type":"function","name":"test_Increment"}],"bytecode":{"object":"0x6080"}}`,
+		"Counter.t.sol/BaseTest.json":           `{"abi":[{"type":"function","name":"test_Base"}],"bytecode":{"object":"0x"}}`,
+		"Counter.sol/Counter.json":              `{"abi":[{"type":"function","name":"increment"}],"bytecode":"0x6080"}`,
+		"Counter.sol/Counter.abi":               `[]`,
+		"build-info/abc.json":                   `{"not":"an artifact"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+	return dir
+}
+
+func TestList(t *testing.T) {
+	tests, err := List([]string{writeArtifacts(t)})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	expected := []Test{
+		{"CounterTest", "invariant_Total"},
+		{"CounterTest", "testFuzz_SetNumber"},
+		{"CounterTest", "test_Increment"},
+	}
+	if !reflect.DeepEqual(tests, expected) {
+		t.Errorf("expected %v, got %v", expected, tests)
+	}
+}
+
+func TestShard(t *testing.T) {
+	tests := []Test{{"A", "t1"}, {"A", "t2"}, {"A", "t3"}, {"B", "t1"}, {"B", "t2"}}
+	var all []Test
+	for i := 0; i < 2; i++ {
+		all = append(all, Shard(tests, i, 2)...)
+	}
+	if len(all) != len(tests) {
+		t.Errorf("expected the shards to have every test once, got %v", all)
+	}
+	if shard := Shard(tests, 1, 2); !reflect.DeepEqual(shard, []Test{{"B", "t1"}, {"B", "t2"}}) {
+		t.Errorf("unexpected shard %v", shard)
+	}
+	if shard := Shard(tests, 1, 4); !reflect.DeepEqual(shard, []Test{{"A", "t2"}}) {
+		t.Errorf("expected A's tests to be split, got %v", shard)
+	}
+	if shard := Shard(tests, 5, 6); len(shard) != 0 {
+		t.Errorf("expected an empty shard, got %v", shard)
+	}
+
+	for _, s := range []string{"2/2", "-1/2", "0/0", "1", "a/b"} {
+		if _, _, err := ParseShard(s); err == nil {
+			t.Errorf("expected an error for shard %q", s)
+		}
+	}
+	if i, n, err := ParseShard("1/4"); err != nil || i != 1 || n != 4 {
+		t.Errorf("expected 1/4, got %d/%d (%v)", i, n, err)
+	}
+}
+
+func TestShard_NoOverlap(t *testing.T) {
+	// Every contract has test_a, so the shards would overlap if one held part of
+	// a contract along with any other.
+	var tests []Test
+	for _, contract := range []string{"A", "B", "C"} {
+		for _, name := range []string{"test_a", "test_b", "test_c", "test_d"} {
+			tests = append(tests, Test{contract, name})
+		}
+	}
+	tests = append(tests, Test{"D", "test_a"})
+
+	for count := 1; count <= 15; count++ {
+		ran := map[Test]int{}
+		for index := 0; index < count; index++ {
+			var selectors []string
+			for _, test := range Shard(tests, index, count) {
+				selectors = append(selectors, test.String())
+			}
+			if len(selectors) == 0 {
+				continue
+			}
+			// Run the shard the way forge applies its filters.
+			args := FilterArgs(selectors)
+			matchTest, matchContract := regexp.MustCompile(args[1]), regexp.MustCompile(args[3])
+			for _, test := range tests {
+				if matchContract.MatchString(test.Contract) && matchTest.MatchString(test.Name) {
+					ran[test]++
+				}
+			}
+		}
+		for _, test := range tests {
+			if ran[test] != 1 {
+				t.Errorf("%d shards: expected %s to run once, ran %d times", count, test, ran[test])
+			}
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	tests := []Test{{"A", "test_X"}, {"A", "test_Y"}, {"B", "test_X"}}
+	if selected := Select(tests, []string{"test_X"}); len(selected) != 2 {
+		t.Errorf("expected test_X in both contracts, got %v", selected)
+	}
+	if selected := Select(tests, []string{"B.test_X", "test_Z"}); !reflect.DeepEqual(selected, []Test{{"B", "test_X"}}) {
+		t.Errorf("expected only B.test_X, got %v", selected)
+	}
+}
+
+func TestFilterArgs(t *testing.T) {
+	for _, test := range []struct {
+		selectors []string
+		expected  []string
+	}{
+		{nil, nil},
+		{[]string{"test_X"}, []string{"--match-test", "^(test_X)$"}},
+		{
+			[]string{"B.test_Y", "A.test_X"},
+			[]string{"--match-test", "^(test_X|test_Y)$", "--match-contract", "^(A|B)$"},
+		},
+		{
+			[]string{"A.test_X", "test_Y"},
+			[]string{"--match-test", "^(test_X|test_Y)$"},
+		},
+	} {
+		if args := FilterArgs(test.selectors); !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("%q: expected %q, got %q", test.selectors, test.expected, args)
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	for _, test := range []struct {
+		args        string
+		tests, rest []string
+	}{
+		{"", nil, nil},
+		{" test_X  A.test_Y ", []string{"test_X", "A.test_Y"}, nil},
+		{"test_X --replay=f.json -vvv test_Y", []string{"test_X"}, []string{"--replay=f.json", "-vvv", "test_Y"}},
+		{"-vvv", nil, []string{"-vvv"}},
+	} {
+		tests, rest := SplitArgs(test.args)
+		if !reflect.DeepEqual(tests, test.tests) || !reflect.DeepEqual(rest, test.rest) {
+			t.Errorf("%q: expected %q and %q, got %q and %q", test.args, test.tests, test.rest, tests, rest)
+		}
+	}
+}
+
+func TestNarrow(t *testing.T) {
+	artifacts := writeArtifacts(t)
+	for _, test := range []struct {
+		selectors []string
+		shard     string
+		expected  []string
+		ok        bool
+	}{
+		{nil, "", nil, true},
+		{[]string{"test_Increment"}, "", []string{"--match-test", "^(test_Increment)$"}, true},
+		// CounterTest's three tests are dealt out between three shards in order.
+		{nil, "0/3", []string{"--match-test", "^(invariant_Total)$", "--match-contract", "^(CounterTest)$"}, true},
+		{[]string{"test_Increment", "testFuzz_SetNumber"}, "1/3", []string{"--match-test", "^(testFuzz_SetNumber)$", "--match-contract", "^(CounterTest)$"}, true},
+		{[]string{"test_Increment"}, "0/3", nil, false},
+		{nil, "3/4", nil, false},
+	} {
+		args, ok, err := Narrow(test.selectors, test.shard, artifacts)
+		if err != nil {
+			t.Fatalf("Narrow failed: %v", err)
+		}
+		if ok != test.ok || !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("%q in shard %q: expected %q (%v), got %q (%v)", test.selectors, test.shard, test.expected, test.ok, args, ok)
+		}
+	}
+	if _, _, err := Narrow(nil, "4/4", artifacts); err == nil {
+		t.Error("expected an error for an invalid shard")
+	}
+}
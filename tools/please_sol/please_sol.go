@@ -4,16 +4,31 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/peterebden/go-cli-init/v5/flags"
 
+	"tools/please_sol/coverage"
+	"tools/please_sol/depindex"
 	"tools/please_sol/detectprefix"
 	"tools/please_sol/forgewrap"
 	"tools/please_sol/foundrytoml"
+	"tools/please_sol/gasreport"
+	"tools/please_sol/gassnapshot"
+	"tools/please_sol/genbuild"
+	"tools/please_sol/ideconfig"
+	"tools/please_sol/importgraph"
+	"tools/please_sol/listcontracts"
+	"tools/please_sol/listtests"
+	"tools/please_sol/solcversion"
+	"tools/please_sol/testrun"
 )
 
 var opts = struct {
@@ -27,24 +42,149 @@ var opts = struct {
 	} `command:"detect-prefix" description:"Detect import prefix from a Solidity library's package.json"`
 
 	ForgeWrap struct {
-		ForgePath     string   `short:"f" long:"forge" required:"true" description:"Path to the forge binary"`
-		RemappingFile string   `short:"r" long:"remapping-file" description:"Path to file containing remappings (one per line)"`
-		Args          []string `positional-args:"true" description:"Arguments to pass to forge"`
+		ForgePath     string        `short:"f" long:"forge" required:"true" description:"Path to the forge binary"`
+		RemappingFile string        `short:"r" long:"remapping-file" description:"Path to file containing remappings (one per line)"`
+		PathMap       string        `long:"path-map" description:"Manifest mapping paths in forge's working directory to workspace paths, one \"from to [target]\" per line"`
+		Index         string        `long:"index" description:"Index of targets from dep-index, used to suggest the target that provides an unresolved import"`
+		Rules         string        `long:"rules" description:"TOML file of hint rules to use in addition to (or instead of) the built-in ones"`
+		GenDir        string        `long:"gen-dir" description:"Directory Please writes sol_get outputs to, for mapping their paths (default: plz-out/gen)"`
+		Format        string        `long:"format" default:"text" choice:"text" choice:"json" description:"Output format: text prints forge's output with hints; json prints structured diagnostics on stdout and forge's output on stderr"`
+		Timeout       time.Duration `long:"timeout" description:"Kill forge and any solc processes it started if it runs longer than this, e.g. 10m"`
+		Warnings      string        `long:"warnings" default:"report" choice:"ignore" choice:"report" choice:"error" description:"What to do with compiler warnings: ignore them, report them, or fail on any not allowed by --allow-warning"`
+		AllowWarnings []string      `long:"allow-warning" description:"solc warning code (e.g. 2072) that doesn't fail with --warnings=error. Can be repeated"`
+		Args          struct {
+			Args []string `positional-arg-name:"args" description:"Arguments to pass to forge, after --"`
+		} `positional-args:"true"`
 	} `command:"forge-wrap" description:"Run forge with enhanced error messages"`
 
 	ParseFoundry struct {
-		File    string `short:"f" long:"file" required:"true" description:"Path to foundry.toml file"`
-		Profile string `short:"p" long:"profile" description:"Profile to extract (default: default)"`
-		Output  string `short:"o" long:"output" description:"Output format: json, solc-version, remappings, optimizer (default: json)"`
+		File        string `short:"f" long:"file" required:"true" description:"Path to foundry.toml file"`
+		Profile     string `short:"p" long:"profile" description:"Profile to extract (default: $FOUNDRY_PROFILE, or default)"`
+		Output      string `short:"o" long:"output" description:"Output format: json, solc-version, remappings, optimizer, evm-version, test-env, fmt, forge-flags (default: json)"`
+		Test        bool   `long:"test" description:"Include forge test flags (fuzz settings) in forge-flags output"`
+		ShowSources bool   `long:"show-sources" description:"Print each resolved value of the profile and the file section or environment variable it came from"`
+		Strict      bool   `long:"strict" description:"Fail if foundry.toml contains unknown keys, instead of warning about them"`
 	} `command:"parse-foundry" description:"Parse foundry.toml and extract configuration"`
+
+	ListContracts struct {
+		Args struct {
+			Paths []string `positional-arg-name:"paths" required:"true" description:"Solidity files or directories to scan"`
+		} `positional-args:"true"`
+	} `command:"list-contracts" description:"List contracts, interfaces and libraries declared in Solidity sources"`
+
+	Imports struct {
+		RemappingFiles []string `short:"r" long:"remapping-file" description:"Path to a file containing remappings (one per line). May be repeated."`
+		Args           struct {
+			Paths []string `positional-arg-name:"paths" required:"true" description:"Solidity files or directories to scan"`
+		} `positional-args:"true"`
+	} `command:"imports" description:"Resolve the imports of Solidity sources into a JSON graph"`
+
+	GenerateBuild struct {
+		Dir            string   `short:"d" long:"dir" required:"true" description:"Package directory to generate a BUILD file for, relative to the repo root"`
+		RemappingFiles []string `short:"r" long:"remapping-file" description:"Path to a file containing remappings (one per line). May be repeated."`
+		RemappingDir   string   `long:"remapping-dir" description:"Directory to search for .remapping files (e.g. plz-out/gen)"`
+		SolcVersion    string   `short:"s" long:"solc-version" description:"solc_version to set on every generated rule"`
+		Subinclude     string   `long:"subinclude" description:"Label of the solidity build_defs to subinclude (default: ///solidity//build_defs:solidity)"`
+		Out            string   `short:"o" long:"out" description:"File to write the BUILD file to (default: stdout)"`
+	} `command:"generate-build" description:"Generate sol_contract/sol_test rules for a package from its imports"`
+
+	ResolveSolc struct {
+		Versions       []string `short:"v" long:"version" required:"true" description:"An available solc version. May be repeated."`
+		RemappingFiles []string `short:"r" long:"remapping-file" description:"Path to a file containing remappings (one per line). May be repeated."`
+		Remappings     []string `long:"remappings" description:"A remapping in forge's prefix=target form. May be repeated."`
+		Args           struct {
+			Paths []string `positional-arg-name:"paths" required:"true" description:"Solidity files or directories whose transitive sources to check"`
+		} `positional-args:"true"`
+	} `command:"resolve-solc" description:"Pick the newest solc version satisfying every pragma in the given sources"`
+
+	IdeConfig struct {
+		RemappingFiles []string `short:"r" long:"remapping-file" description:"Path to a file containing remappings (one per line). May be repeated."`
+		RemappingDir   string   `long:"remapping-dir" description:"Directory to search for .remapping files (e.g. plz-out/gen)"`
+		GenDir         string   `long:"gen-dir" description:"Directory that remapping targets are relative to (default: plz-out/gen)"`
+		SolcVersion    string   `short:"s" long:"solc-version" description:"solc_version to write to foundry.toml"`
+		EvmVersion     string   `long:"evm-version" description:"evm_version to write to foundry.toml"`
+		Optimize       bool     `long:"optimize" description:"Enable the optimizer"`
+		OptimizerRuns  int      `long:"optimizer-runs" description:"Number of optimizer runs"`
+		Src            string   `long:"src" description:"Source directory for forge to compile by default"`
+		OutDir         string   `short:"o" long:"out-dir" default:"." description:"Directory to write foundry.toml and remappings.txt to"`
+	} `command:"ide-config" description:"Write a foundry.toml and remappings.txt that resolve imports the way the build does"`
+
+	DepIndex struct {
+		Targets      []string `short:"t" long:"target" description:"A target and its outputs, as \"label=path path...\". May be repeated."`
+		RemappingDir string   `long:"remapping-dir" description:"Directory to search for .remapping files, indexing the sol_get outputs they point to (e.g. plz-out/gen)"`
+	} `command:"dep-index" description:"Index the files and import prefixes of Solidity targets, for forge-wrap --index"`
+
+	TestReport struct {
+		Input     string `short:"i" long:"input" description:"File of forge test --json output to read if no command is given. Defaults to stdin."`
+		Out       string `short:"o" long:"out" required:"true" description:"File to write JUnit XML results to, e.g. $RESULTS_FILE"`
+		Corpus    string `long:"corpus" description:"File to save failed fuzz and invariant tests to, for replaying with --replay=FILE in the command's arguments. The command is given a --fuzz-seed if it hasn't one"`
+		Tests     string `long:"tests" description:"Space-separated tests to run, as function names or Contract.name, e.g. $TESTS. The command is given forge's filters for them"`
+		TestArgs  string `long:"test-args" description:"The test's arguments, e.g. $TEST_ARGS. Leading words select tests as --tests does; the rest are added to the command"`
+		Shard     string `long:"shard" description:"Only run shard INDEX/COUNT of the tests in --artifacts, e.g. 0/4"`
+		Artifacts string `long:"artifacts" default:"out" description:"forge's artifacts directory, to list the tests in for --shard"`
+		Args      struct {
+			Command []string `positional-arg-name:"command" description:"Command that runs forge test --json, after --"`
+		} `positional-args:"true"`
+	} `command:"test-report" description:"Run or read forge test --json and write the results as JUnit XML"`
+
+	GasDiff struct {
+		Golden             string  `short:"g" long:"golden" required:"true" description:"Checked-in .gas-snapshot file to compare against"`
+		Snapshot           string  `short:"s" long:"snapshot" required:"true" description:".gas-snapshot file written by forge snapshot"`
+		MaxIncreasePercent float64 `long:"max-increase-percent" description:"Largest rise in a test's gas allowed, as a percentage. If neither limit is set, any rise fails"`
+		MaxIncreaseGas     uint64  `long:"max-increase-gas" description:"Largest rise in a test's gas allowed, in gas. If neither limit is set, any rise fails"`
+		Update             bool    `long:"update" description:"Overwrite the golden file with the snapshot instead of failing on regressions"`
+	} `command:"gas-diff" description:"Compare a gas snapshot with a golden file and fail on regressions"`
+
+	GasReport struct {
+		Markdown string `short:"m" long:"markdown" description:"File to write the merged report to as Markdown. Defaults to stdout if --json isn't given either"`
+		JSON     string `short:"j" long:"json" description:"File to write the merged report to as JSON"`
+		Args     struct {
+			Reports []string `positional-arg-name:"report" required:"1" description:"Files of forge test --gas-report output, as tables or JSON"`
+		} `positional-args:"true"`
+	} `command:"gas-report" description:"Merge the gas reports of many forge test runs per contract and function"`
+
+	Coverage struct {
+		Out               string   `short:"o" long:"out" description:"File to write the merged coverage to, e.g. $COVERAGE_FILE. Defaults to stdout"`
+		Format            string   `long:"format" choice:"cobertura" choice:"lcov" default:"cobertura" description:"Format to write: cobertura for Please, or lcov"`
+		Remappings        []string `long:"remappings" description:"A remapping in forge's prefix=target form, to map sol_get files back to their outputs. May be repeated."`
+		GenDir            string   `long:"gen-dir" description:"Directory that remapping targets are relative to (default: plz-out/gen)"`
+		IncludeThirdParty bool     `long:"include-third-party" description:"Keep the coverage of sol_get files, which is dropped by default"`
+		Args              struct {
+			Reports []string `positional-arg-name:"lcov" required:"1" description:"LCOV files written by forge coverage --report lcov"`
+		} `positional-args:"true"`
+	} `command:"coverage" description:"Merge forge coverage LCOV reports, with paths mapped back to the workspace"`
+
+	ListTests struct {
+		Shard string `long:"shard" description:"Only list shard INDEX/COUNT of the tests, e.g. 0/4"`
+		Args  struct {
+			Paths []string `positional-arg-name:"artifacts" required:"1" description:"forge artifact JSON files, or directories such as out/ to search"`
+		} `positional-args:"true"`
+	} `command:"list-tests" description:"List the test functions in compiled test contracts as Contract.name"`
+
+	TestArgs struct {
+		Tests    string `long:"tests" description:"Space-separated tests to run, as function names or Contract.name, e.g. $TESTS"`
+		TestArgs string `long:"test-args" description:"The test's arguments, e.g. $TEST_ARGS. Leading words select tests as --tests does"`
+	} `command:"test-args" description:"Print a test's arguments as shell-quoted forge arguments for commands other than test-report's"`
 }{
 	Usage: `
 please_sol is used by the solidity build rules to perform complex parsing operations.
 
 Supported commands:
-  detect-prefix  Auto-detect import prefixes from package.json files
-  forge-wrap     Run forge with enhanced error messages
-  parse-foundry  Parse foundry.toml configuration files
+  detect-prefix   Auto-detect import prefixes from package.json files
+  forge-wrap      Run forge with enhanced error messages
+  parse-foundry   Parse foundry.toml configuration files
+  list-contracts  List contracts, interfaces and libraries in Solidity sources
+  imports         Resolve Solidity imports into a JSON dependency graph
+  generate-build  Generate a BUILD file for a package from its imports
+  resolve-solc    Pick a solc version from the pragmas of Solidity sources
+  ide-config      Write foundry.toml and remappings.txt for editors and forge
+  dep-index       Index which targets provide which Solidity import paths
+  test-report     Write forge test --json results as JUnit XML for Please
+  gas-diff        Compare a gas snapshot with a golden file
+  gas-report      Merge gas reports from many forge test runs
+  coverage        Convert forge coverage LCOV reports for plz cover
+  list-tests      List the test functions in compiled test contracts
+  test-args       Turn a test's arguments into forge filters for forge coverage
 `,
 }
 
@@ -78,37 +218,97 @@ var subCommands = map[string]func() int{
 			}
 		}
 
+		// Remappings passed to forge count too
+		remappings = append(remappings, forgewrap.RemappingsFromArgs(fw.Args.Args)...)
+
+		var roots []string
+		if wd, err := os.Getwd(); err == nil {
+			roots = append(roots, wd)
+			if resolved, err := filepath.EvalSymlinks(wd); err == nil && resolved != wd {
+				roots = append(roots, resolved)
+			}
+		}
+		paths := forgewrap.NewPathMap(roots...)
+		if fw.PathMap != "" {
+			if err := paths.LoadManifest(fw.PathMap); err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+		paths.AddRemappings(remappings, fw.GenDir)
+
 		wrapper := forgewrap.New(remappings)
-		result, err := wrapper.Run(fw.ForgePath, fw.Args)
+		wrapper.SetPathMap(paths)
+		wrapper.SetTimeout(fw.Timeout)
+		wrapper.SetWarnings(forgewrap.WarningPolicy(fw.Warnings), fw.AllowWarnings)
+		if fw.Rules != "" {
+			rules, err := forgewrap.LoadRules(fw.Rules)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			wrapper.SetRules(rules)
+		}
+		if fw.Index != "" {
+			index, err := depindex.Load(fw.Index)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			wrapper.SetIndex(index)
+		}
+		// forge's output is streamed as it runs. In JSON mode it all goes to stderr,
+		// leaving stdout for the diagnostics.
+		stdout := io.Writer(os.Stdout)
+		if fw.Format == "json" {
+			stdout = os.Stderr
+		}
+		result, err := wrapper.Stream(fw.ForgePath, fw.Args.Args, stdout, os.Stderr)
 		if err != nil {
 			log.Fatalf("failed to run forge: %v", err)
 		}
 
-		// Output stdout
-		if result.Stdout != "" {
-			fmt.Print(result.Stdout)
-		}
-
-		// Output stderr (enhanced if applicable)
-		if result.Enhanced != "" {
-			fmt.Fprint(os.Stderr, result.Enhanced)
-		} else if result.Stderr != "" {
-			fmt.Fprint(os.Stderr, result.Stderr)
+		if fw.Format == "json" {
+			data, err := result.ToJSON()
+			if err != nil {
+				log.Fatalf("failed to encode diagnostics: %v", err)
+			}
+			fmt.Println(string(data))
+		} else {
+			fmt.Fprint(os.Stderr, wrapper.FormatResult(result))
 		}
-
 		return result.ExitCode
 	},
 	"parse-foundry": func() int {
 		pf := opts.ParseFoundry
 
+		unknown, err := foundrytoml.CheckFile(pf.File)
+		if err != nil {
+			log.Fatalf("failed to parse foundry.toml: %v", err)
+		}
+		for _, u := range unknown {
+			if pf.Strict {
+				fmt.Fprintf(os.Stderr, "%s:%s\n", pf.File, u)
+			} else {
+				fmt.Fprintf(os.Stderr, "warning: %s:%s\n", pf.File, u)
+			}
+		}
+		if pf.Strict && len(unknown) > 0 {
+			return 1
+		}
+
 		config, err := foundrytoml.ParseFile(pf.File)
 		if err != nil {
 			log.Fatalf("failed to parse foundry.toml: %v", err)
 		}
+		config, err = config.WithEnv(foundrytoml.EnvMap(os.Environ()))
+		if err != nil {
+			log.Fatalf("failed to apply environment overrides: %v", err)
+		}
 
-		profile := pf.Profile
-		if profile == "" {
-			profile = "default"
+		profile := config.ActiveProfile(pf.Profile)
+		if pf.ShowSources {
+			if err := foundrytoml.WriteSources(os.Stdout, config.Sources(profile)); err != nil {
+				log.Fatalf("failed to write sources: %v", err)
+			}
+			return 0
 		}
 
 		output := pf.Output
@@ -118,7 +318,7 @@ var subCommands = map[string]func() int{
 
 		switch output {
 		case "json":
-			jsonBytes, err := config.ToJSON()
+			jsonBytes, err := config.ResolveProfiles().ToJSON()
 			if err != nil {
 				log.Fatalf("failed to convert to JSON: %v", err)
 			}
@@ -150,12 +350,363 @@ var subCommands = map[string]func() int{
 				fmt.Println(evmVersion)
 			}
 
+		case "test-env":
+			for _, line := range config.TestEnv(profile) {
+				fmt.Println(line)
+			}
+
+		case "forge-flags":
+			root := filepath.ToSlash(filepath.Dir(pf.File))
+			fmt.Println(foundrytoml.ShellJoin(config.ForgeFlags(profile, pf.Test, root)))
+
+		case "fmt":
+			data, err := config.FmtTOML(profile)
+			if err != nil {
+				log.Fatalf("failed to convert to TOML: %v", err)
+			}
+			fmt.Print(string(data))
+
 		default:
 			log.Fatalf("unknown output format: %s", output)
 		}
 
 		return 0
 	},
+	"list-contracts": func() int {
+		entries, err := listcontracts.List(opts.ListContracts.Args.Paths)
+		if err != nil {
+			log.Fatalf("failed to list contracts: %v", err)
+		}
+		if err := listcontracts.Write(os.Stdout, entries); err != nil {
+			log.Fatalf("failed to write contracts: %v", err)
+		}
+		return 0
+	},
+	"imports": func() int {
+		im := opts.Imports
+
+		remappings, err := importgraph.LoadRemappingFiles(im.RemappingFiles)
+		if err != nil {
+			log.Fatalf("failed to load remappings: %v", err)
+		}
+
+		graph, err := importgraph.NewResolver(remappings).Build(im.Args.Paths)
+		if err != nil {
+			log.Fatalf("failed to build import graph: %v", err)
+		}
+
+		jsonBytes, err := graph.ToJSON()
+		if err != nil {
+			log.Fatalf("failed to convert to JSON: %v", err)
+		}
+		fmt.Println(string(jsonBytes))
+		return 0
+	},
+	"generate-build": func() int {
+		gb := opts.GenerateBuild
+
+		remappingFiles := gb.RemappingFiles
+		if gb.RemappingDir != "" {
+			found, err := importgraph.FindRemappingFiles(gb.RemappingDir)
+			if err != nil {
+				log.Fatalf("failed to find remappings: %v", err)
+			}
+			remappingFiles = append(remappingFiles, found...)
+		}
+		remappings, err := importgraph.LoadRemappingFiles(remappingFiles)
+		if err != nil {
+			log.Fatalf("failed to load remappings: %v", err)
+		}
+
+		generator := genbuild.New(remappings, gb.SolcVersion, gb.Subinclude)
+		rules, err := generator.Rules(gb.Dir)
+		if err != nil {
+			log.Fatalf("failed to generate rules: %v", err)
+		}
+
+		out := os.Stdout
+		if gb.Out != "" {
+			out, err = os.Create(gb.Out)
+			if err != nil {
+				log.Fatalf("failed to create %s: %v", gb.Out, err)
+			}
+		}
+		if err := generator.Write(out, rules); err != nil {
+			log.Fatalf("failed to write BUILD file: %v", err)
+		}
+		if gb.Out != "" {
+			if err := out.Close(); err != nil {
+				log.Fatalf("failed to write BUILD file: %v", err)
+			}
+		}
+		return 0
+	},
+	"resolve-solc": func() int {
+		rs := opts.ResolveSolc
+
+		var available []solcversion.Version
+		for _, s := range rs.Versions {
+			v, err := solcversion.ParseVersion(s)
+			if err != nil {
+				log.Fatalf("invalid --version: %v", err)
+			}
+			available = append(available, v)
+		}
+
+		remappings, err := importgraph.LoadRemappingFiles(rs.RemappingFiles)
+		if err != nil {
+			log.Fatalf("failed to load remappings: %v", err)
+		}
+		for _, line := range rs.Remappings {
+			r, err := importgraph.ParseRemapping(line)
+			if err != nil {
+				log.Fatalf("invalid --remappings: %v", err)
+			}
+			remappings = append(remappings, r)
+		}
+
+		constraints, err := solcversion.Collect(rs.Args.Paths, importgraph.NewResolver(remappings))
+		if err != nil {
+			log.Fatalf("failed to read pragmas: %v", err)
+		}
+		version, err := solcversion.Resolve(constraints, available)
+		if err != nil {
+			log.Fatalf("failed to resolve solc version: %v", err)
+		}
+		fmt.Println(version)
+		return 0
+	},
+	"ide-config": func() int {
+		ic := opts.IdeConfig
+
+		remappingFiles := ic.RemappingFiles
+		if ic.RemappingDir != "" {
+			found, err := importgraph.FindRemappingFiles(ic.RemappingDir)
+			if err != nil {
+				log.Fatalf("failed to find remappings: %v", err)
+			}
+			remappingFiles = append(remappingFiles, found...)
+		}
+		remappings, err := importgraph.LoadRemappingFiles(remappingFiles)
+		if err != nil {
+			log.Fatalf("failed to load remappings: %v", err)
+		}
+
+		config := ideconfig.Config(ideconfig.Options{
+			SolcVersion:   ic.SolcVersion,
+			EvmVersion:    ic.EvmVersion,
+			Optimizer:     ic.Optimize,
+			OptimizerRuns: ic.OptimizerRuns,
+			Src:           ic.Src,
+		})
+		if err := ideconfig.Write(ic.OutDir, config, ideconfig.Remappings(remappings, ic.GenDir)); err != nil {
+			log.Fatalf("failed to write IDE config: %v", err)
+		}
+		return 0
+	},
+	"dep-index": func() int {
+		di := opts.DepIndex
+
+		index := &depindex.Index{}
+		for _, target := range di.Targets {
+			label, paths, ok := strings.Cut(target, "=")
+			if !ok || label == "" {
+				log.Fatalf("invalid --target %q: expected label=paths", target)
+			}
+			if err := index.Add(label, ".", strings.Fields(paths)); err != nil {
+				log.Fatalf("failed to index %s: %v", label, err)
+			}
+		}
+		if di.RemappingDir != "" {
+			if err := index.AddRemappingDir(di.RemappingDir); err != nil {
+				log.Fatalf("failed to index %s: %v", di.RemappingDir, err)
+			}
+		}
+
+		data, err := index.ToJSON()
+		if err != nil {
+			log.Fatalf("failed to encode index: %v", err)
+		}
+		fmt.Println(string(data))
+		return 0
+	},
+	"test-report": func() int {
+		tr := opts.TestReport
+
+		exitCode, err := testrun.Run(testrun.Options{
+			Command:   tr.Args.Command,
+			Input:     tr.Input,
+			Out:       tr.Out,
+			Corpus:    tr.Corpus,
+			Tests:     strings.Fields(tr.Tests),
+			TestArgs:  tr.TestArgs,
+			Shard:     tr.Shard,
+			Artifacts: tr.Artifacts,
+			Root:      ".",
+		}, os.Stdin, os.Stdout, os.Stderr)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		return exitCode
+	},
+	"gas-diff": func() int {
+		gd := opts.GasDiff
+
+		snapshot, err := gassnapshot.Load(gd.Snapshot)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		golden, err := gassnapshot.Load(gd.Golden)
+		if errors.Is(err, os.ErrNotExist) && gd.Update {
+			golden = nil
+		} else if errors.Is(err, os.ErrNotExist) {
+			log.Fatalf("%s doesn't exist; plz run the sol_gas_snapshot rule to create it", gd.Golden)
+		} else if err != nil {
+			log.Fatalf("%v", err)
+		} else if len(golden) == 0 && !gd.Update {
+			// Every test would count as added, and an added test is never a regression.
+			log.Fatalf("%s has no entries; plz run the sol_gas_snapshot rule to fill it", gd.Golden)
+		}
+
+		thresholds := gassnapshot.Thresholds{Percent: gd.MaxIncreasePercent, Gas: gd.MaxIncreaseGas}
+		changes := gassnapshot.Compare(golden, snapshot, thresholds)
+		if err := gassnapshot.WriteTable(os.Stdout, changes); err != nil {
+			log.Fatalf("failed to write changes: %v", err)
+		}
+
+		if gd.Update {
+			data, err := os.ReadFile(gd.Snapshot)
+			if err != nil {
+				log.Fatalf("failed to read gas snapshot: %v", err)
+			}
+			if err := os.WriteFile(gd.Golden, data, 0644); err != nil {
+				log.Fatalf("failed to write golden file: %v", err)
+			}
+			fmt.Printf("Updated %s\n", gd.Golden)
+			return 0
+		}
+		if n := gassnapshot.Regressions(changes); n > 0 {
+			fmt.Printf("\n%d test(s) used more gas than allowed; if that's expected, plz run the sol_gas_snapshot rule to update %s\n", n, gd.Golden)
+			return 1
+		}
+		return 0
+	},
+	"gas-report": func() int {
+		gr := opts.GasReport
+
+		var reports []*gasreport.Report
+		for _, filename := range gr.Args.Reports {
+			report, err := gasreport.Load(filename)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			reports = append(reports, report)
+		}
+		merged := gasreport.Merge(reports...)
+
+		if gr.Markdown == "" && gr.JSON == "" {
+			if err := merged.WriteMarkdown(os.Stdout); err != nil {
+				log.Fatalf("failed to write gas report: %v", err)
+			}
+			return 0
+		}
+		outputs := []struct {
+			filename string
+			write    func(io.Writer) error
+		}{
+			{gr.Markdown, merged.WriteMarkdown},
+			{gr.JSON, merged.WriteJSON},
+		}
+		for _, out := range outputs {
+			if out.filename == "" {
+				continue
+			}
+			f, err := os.Create(out.filename)
+			if err != nil {
+				log.Fatalf("failed to create %s: %v", out.filename, err)
+			}
+			if err := out.write(f); err != nil {
+				log.Fatalf("failed to write %s: %v", out.filename, err)
+			}
+			if err := f.Close(); err != nil {
+				log.Fatalf("failed to write %s: %v", out.filename, err)
+			}
+		}
+		return 0
+	},
+	"coverage": func() int {
+		cv := opts.Coverage
+
+		// forge reports paths in its working directory, where sol_get files are at
+		// their remapping targets rather than under plz-out.
+		var roots []string
+		if wd, err := os.Getwd(); err == nil {
+			roots = append(roots, wd)
+			if resolved, err := filepath.EvalSymlinks(wd); err == nil && resolved != wd {
+				roots = append(roots, resolved)
+			}
+		}
+		paths := forgewrap.NewPathMap(roots...)
+		paths.AddRemappings(cv.Remappings, cv.GenDir)
+		mapPath := func(filename string) (string, bool) {
+			mapped, target, _ := paths.Map(filename)
+			if target != "" && !cv.IncludeThirdParty {
+				return "", false
+			}
+			// Files outside the working directory aren't part of the workspace.
+			return mapped, !filepath.IsAbs(mapped) && !strings.HasPrefix(mapped, "../")
+		}
+
+		merged := coverage.New()
+		for _, filename := range cv.Args.Reports {
+			report, err := coverage.LoadLCOV(filename)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			merged.Merge(report.Rewrite(mapPath))
+		}
+
+		out := io.Writer(os.Stdout)
+		if cv.Out != "" {
+			f, err := os.Create(cv.Out)
+			if err != nil {
+				log.Fatalf("failed to create %s: %v", cv.Out, err)
+			}
+			defer f.Close()
+			out = f
+		}
+		write := merged.WriteCobertura
+		if cv.Format == "lcov" {
+			write = merged.WriteLCOV
+		}
+		if err := write(out); err != nil {
+			log.Fatalf("failed to write coverage: %v", err)
+		}
+		return 0
+	},
+	"list-tests": func() int {
+		lt := opts.ListTests
+		tests, err := listtests.List(lt.Args.Paths)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if lt.Shard != "" {
+			index, count, err := listtests.ParseShard(lt.Shard)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			tests = listtests.Shard(tests, index, count)
+		}
+		for _, t := range tests {
+			fmt.Println(t)
+		}
+		return 0
+	},
+	"test-args": func() int {
+		ta := opts.TestArgs
+		fmt.Println(foundrytoml.ShellJoin(testrun.ForgeArgs(strings.Fields(ta.Tests), ta.TestArgs)))
+		return 0
+	},
 }
 
 func main() {
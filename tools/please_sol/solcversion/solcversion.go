@@ -0,0 +1,352 @@
+// Package solcversion picks a solc version that satisfies the pragmas of a set of Solidity sources.
+//
+// Version constraints follow the npm-style semver ranges solc accepts:
+//
+//   - Comparators: =0.8.19, >0.8.0, >=0.8.4, <0.9.0, <=0.8.24
+//   - Caret and tilde ranges: ^0.8.0 (>=0.8.0 <0.9.0), ~0.8.4 (>=0.8.4 <0.9.0)
+//   - Partial and wildcard versions: 0.8, 0.8.x, *
+//   - Hyphen ranges: 0.8.0 - 0.8.10
+//   - Alternatives separated by ||
+//
+// Space-separated comparators must all hold.
+package solcversion
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"tools/please_sol/importgraph"
+	"tools/please_sol/solparse"
+)
+
+// Version is a solc release version.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// ParseVersion parses a full "major.minor.patch" version.
+func ParseVersion(s string) (Version, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid version %q: expected major.minor.patch", s)
+	}
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+		nums[i] = n
+	}
+	return Version{nums[0], nums[1], nums[2]}, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is less than, equal to or greater than o.
+func (v Version) Compare(o Version) int {
+	for _, d := range [3]int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// comparator is a single bound, e.g. ">=0.8.4".
+type comparator struct {
+	op      string
+	version Version
+}
+
+func (c comparator) matches(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// Constraint is a parsed version constraint, as found in a `pragma solidity` directive.
+type Constraint struct {
+	text string
+	// alternatives are OR'd together; the comparators within each are AND'd.
+	alternatives [][]comparator
+}
+
+func (c *Constraint) String() string {
+	return c.text
+}
+
+// Matches returns true if v satisfies the constraint.
+func (c *Constraint) Matches(v Version) bool {
+	for _, alt := range c.alternatives {
+		ok := true
+		for _, comp := range alt {
+			if !comp.matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	comparatorPattern = regexp.MustCompile(`^(\^|~|>=|<=|>|<|=)?\s*(\*|[xX]|\d+)(?:\.(\*|[xX]|\d+))?(?:\.(\*|[xX]|\d+))?$`)
+	operatorSpacing   = regexp.MustCompile(`(\^|~|>=|<=|>|<|=)\s+`)
+)
+
+// ParseConstraint parses a version constraint such as "^0.8.0" or ">=0.8.4 <0.9.0".
+func ParseConstraint(text string) (*Constraint, error) {
+	c := &Constraint{text: strings.TrimSpace(text)}
+	for _, alt := range strings.Split(c.text, "||") {
+		alt = strings.TrimSpace(alt)
+		var comps []comparator
+		if lo, hi, ok := strings.Cut(alt, " - "); ok {
+			lower, err := parseComparator(">=" + strings.TrimSpace(lo))
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %q: %w", text, err)
+			}
+			upper, err := parseComparator("<=" + strings.TrimSpace(hi))
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %q: %w", text, err)
+			}
+			comps = append(lower, upper...)
+		} else {
+			for _, field := range strings.Fields(operatorSpacing.ReplaceAllString(alt, "$1")) {
+				parsed, err := parseComparator(field)
+				if err != nil {
+					return nil, fmt.Errorf("invalid constraint %q: %w", text, err)
+				}
+				comps = append(comps, parsed...)
+			}
+		}
+		if len(comps) == 0 {
+			return nil, fmt.Errorf("invalid constraint %q: empty range", text)
+		}
+		c.alternatives = append(c.alternatives, comps)
+	}
+	return c, nil
+}
+
+// parseComparator expands a single comparator, which may use a partial version or
+// a caret/tilde range, into one or two plain bounds.
+func parseComparator(s string) ([]comparator, error) {
+	m := comparatorPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid comparator %q", s)
+	}
+	op := m[1]
+
+	// Count how many leading components are specified; wildcards end the version.
+	var nums [3]int
+	specified := 0
+	for i, part := range m[2:] {
+		if part == "" || part == "*" || part == "x" || part == "X" {
+			break
+		}
+		nums[i], _ = strconv.Atoi(part)
+		specified++
+	}
+	lower := Version{nums[0], nums[1], nums[2]}
+	if specified == 0 {
+		if op == "<" || op == ">" {
+			return nil, fmt.Errorf("comparator %q matches nothing", s)
+		}
+		return []comparator{{op: ">=", version: Version{}}}, nil
+	}
+
+	// next returns the smallest version beyond the range covered by the first n components.
+	next := func(n int) Version {
+		switch n {
+		case 1:
+			return Version{lower.Major + 1, 0, 0}
+		case 2:
+			return Version{lower.Major, lower.Minor + 1, 0}
+		default:
+			return Version{lower.Major, lower.Minor, lower.Patch + 1}
+		}
+	}
+
+	switch op {
+	case "^":
+		// Everything up to the next change in the leftmost non-zero component.
+		n := 1
+		if lower.Major == 0 && specified >= 2 {
+			n = 2
+			if lower.Minor == 0 && specified == 3 {
+				n = 3
+			}
+		}
+		return []comparator{{">=", lower}, {"<", next(n)}}, nil
+	case "~":
+		n := min(specified, 2)
+		return []comparator{{">=", lower}, {"<", next(n)}}, nil
+	case ">":
+		return []comparator{{">=", next(specified)}}, nil
+	case ">=":
+		return []comparator{{">=", lower}}, nil
+	case "<":
+		return []comparator{{"<", lower}}, nil
+	case "<=":
+		return []comparator{{"<", next(specified)}}, nil
+	default:
+		if specified == 3 {
+			return []comparator{{"=", lower}}, nil
+		}
+		return []comparator{{">=", lower}, {"<", next(specified)}}, nil
+	}
+}
+
+// FileConstraint is a version constraint declared in a source file.
+type FileConstraint struct {
+	File       string
+	Constraint *Constraint
+}
+
+func (fc FileConstraint) String() string {
+	return fmt.Sprintf("%s (%s)", fc.File, fc.Constraint)
+}
+
+// ConflictError is returned when no available version satisfies every constraint.
+type ConflictError struct {
+	// Conflicts lists the constraints that cannot be satisfied together. It holds a
+	// single entry if one file's constraint matches none of the available versions.
+	Conflicts []FileConstraint
+	Available []Version
+}
+
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+	if len(e.Conflicts) == 1 {
+		fmt.Fprintf(&b, "no available solc version satisfies %s", e.Conflicts[0])
+	} else {
+		b.WriteString("conflicting solc version constraints:")
+		for _, fc := range e.Conflicts {
+			fmt.Fprintf(&b, "\n  %s", fc)
+		}
+	}
+	available := make([]string, len(e.Available))
+	for i, v := range e.Available {
+		available[i] = v.String()
+	}
+	fmt.Fprintf(&b, "\navailable versions: %s", strings.Join(available, ", "))
+	return b.String()
+}
+
+// Resolve returns the newest available version that satisfies every constraint.
+func Resolve(constraints []FileConstraint, available []Version) (Version, error) {
+	if len(available) == 0 {
+		return Version{}, fmt.Errorf("no solc versions available")
+	}
+	sorted := append([]Version(nil), available...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Compare(sorted[j]) > 0 })
+
+	for _, v := range sorted {
+		ok := true
+		for _, fc := range constraints {
+			if !fc.Constraint.Matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return v, nil
+		}
+	}
+	return Version{}, &ConflictError{Conflicts: findConflict(constraints, sorted), Available: sorted}
+}
+
+// findConflict returns a small set of constraints that cannot be satisfied together:
+// a single unsatisfiable constraint, the first disjoint pair, or failing that, all of them.
+func findConflict(constraints []FileConstraint, available []Version) []FileConstraint {
+	matching := make([]map[Version]bool, len(constraints))
+	for i, fc := range constraints {
+		matching[i] = map[Version]bool{}
+		for _, v := range available {
+			if fc.Constraint.Matches(v) {
+				matching[i][v] = true
+			}
+		}
+		if len(matching[i]) == 0 {
+			return []FileConstraint{fc}
+		}
+	}
+	for i := range constraints {
+		for j := i + 1; j < len(constraints); j++ {
+			disjoint := true
+			for v := range matching[i] {
+				if matching[j][v] {
+					disjoint = false
+					break
+				}
+			}
+			if disjoint {
+				return []FileConstraint{constraints[i], constraints[j]}
+			}
+		}
+	}
+	return constraints
+}
+
+// Collect gathers the `pragma solidity` constraints of the given sources and every
+// file they transitively import. Imports that don't exist on disk are skipped;
+// forge reports those itself.
+func Collect(paths []string, resolver *importgraph.Resolver) ([]FileConstraint, error) {
+	queue, err := solparse.FindSources(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var constraints []FileConstraint
+	seen := map[string]bool{}
+	for len(queue) > 0 {
+		file := filepath.ToSlash(filepath.Clean(queue[0]))
+		queue = queue[1:]
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+
+		unit, err := solparse.ParseFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, text := range unit.SolidityVersions() {
+			c, err := ParseConstraint(text)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			constraints = append(constraints, FileConstraint{File: file, Constraint: c})
+		}
+		for _, imp := range unit.Imports {
+			resolved, _ := resolver.Resolve(file, imp.Path)
+			if _, err := os.Stat(resolved); err == nil {
+				queue = append(queue, resolved)
+			}
+		}
+	}
+	return constraints, nil
+}
@@ -0,0 +1,189 @@
+package solcversion
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tools/please_sol/importgraph"
+)
+
+func mustVersions(t *testing.T, versions ...string) []Version {
+	t.Helper()
+
+	var out []Version
+	for _, s := range versions {
+		v, err := ParseVersion(s)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) failed: %v", s, err)
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("0.8.20")
+	if err != nil {
+		t.Fatalf("ParseVersion failed: %v", err)
+	}
+	if v != (Version{0, 8, 20}) {
+		t.Errorf("expected 0.8.20, got %v", v)
+	}
+	for _, bad := range []string{"0.8", "0.8.x", "a.b.c", ""} {
+		if _, err := ParseVersion(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestConstraint_Matches(t *testing.T) {
+	tests := []struct {
+		constraint string
+		match      []string
+		noMatch    []string
+	}{
+		{"^0.8.0", []string{"0.8.0", "0.8.26"}, []string{"0.7.6", "0.9.0"}},
+		{"^0.8", []string{"0.8.0", "0.8.26"}, []string{"0.9.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"2.0.0", "1.2.2"}},
+		{"~0.8.4", []string{"0.8.4", "0.8.30"}, []string{"0.8.3", "0.9.0"}},
+		{">=0.8.4 <0.9.0", []string{"0.8.4", "0.8.26"}, []string{"0.8.3", "0.9.0"}},
+		{">= 0.8.4 < 0.9.0", []string{"0.8.4"}, []string{"0.9.0"}},
+		{"=0.8.19", []string{"0.8.19"}, []string{"0.8.20"}},
+		{"0.8.19", []string{"0.8.19"}, []string{"0.8.20"}},
+		{"0.8", []string{"0.8.0", "0.8.30"}, []string{"0.9.0"}},
+		{"0.8.x", []string{"0.8.0", "0.8.30"}, []string{"0.7.0"}},
+		{">0.8", []string{"0.9.0"}, []string{"0.8.30"}},
+		{"<=0.8", []string{"0.8.30"}, []string{"0.9.0"}},
+		{">0.8.19", []string{"0.8.20"}, []string{"0.8.19"}},
+		{"0.8.0 - 0.8.10", []string{"0.8.0", "0.8.10"}, []string{"0.8.11"}},
+		{"^0.7.0 || ^0.8.0", []string{"0.7.6", "0.8.20"}, []string{"0.6.12"}},
+		{"*", []string{"0.4.11", "0.8.30"}, nil},
+		{">=0.4.16", []string{"0.8.20"}, []string{"0.4.15"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			if err != nil {
+				t.Fatalf("ParseConstraint failed: %v", err)
+			}
+			for _, v := range mustVersions(t, tt.match...) {
+				if !c.Matches(v) {
+					t.Errorf("expected %s to match %s", tt.constraint, v)
+				}
+			}
+			for _, v := range mustVersions(t, tt.noMatch...) {
+				if c.Matches(v) {
+					t.Errorf("expected %s not to match %s", tt.constraint, v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseConstraint_Invalid(t *testing.T) {
+	for _, bad := range []string{"", "foo", ">=0.8.a", "^", "0.8.0 ||", "<*"} {
+		if _, err := ParseConstraint(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func constraints(t *testing.T, pairs ...string) []FileConstraint {
+	t.Helper()
+
+	var out []FileConstraint
+	for i := 0; i < len(pairs); i += 2 {
+		c, err := ParseConstraint(pairs[i+1])
+		if err != nil {
+			t.Fatalf("ParseConstraint failed: %v", err)
+		}
+		out = append(out, FileConstraint{File: pairs[i], Constraint: c})
+	}
+	return out
+}
+
+func TestResolve_PicksNewest(t *testing.T) {
+	available := mustVersions(t, "0.8.19", "0.8.24", "0.8.20", "0.7.6")
+	v, err := Resolve(constraints(t,
+		"A.sol", "^0.8.0",
+		"B.sol", ">=0.8.4 <0.8.21",
+	), available)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if v.String() != "0.8.20" {
+		t.Errorf("expected 0.8.20, got %s", v)
+	}
+}
+
+func TestResolve_ConflictingPair(t *testing.T) {
+	available := mustVersions(t, "0.7.6", "0.8.20")
+	_, err := Resolve(constraints(t,
+		"A.sol", ">=0.7.0",
+		"B.sol", "^0.8.0",
+		"Legacy.sol", "^0.7.0",
+	), available)
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected ConflictError, got %v", err)
+	}
+	if len(conflict.Conflicts) != 2 || conflict.Conflicts[0].File != "B.sol" || conflict.Conflicts[1].File != "Legacy.sol" {
+		t.Errorf("expected B.sol and Legacy.sol to conflict, got %v", conflict.Conflicts)
+	}
+	if !strings.Contains(err.Error(), "Legacy.sol (^0.7.0)") {
+		t.Errorf("expected error to name Legacy.sol, got: %v", err)
+	}
+}
+
+func TestResolve_Unsatisfiable(t *testing.T) {
+	available := mustVersions(t, "0.8.20")
+	_, err := Resolve(constraints(t, "Old.sol", "=0.8.19"), available)
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected ConflictError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "no available solc version satisfies Old.sol (=0.8.19)") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCollect_FollowsImports(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	files := map[string]string{
+		"src/Token.sol":          "pragma solidity ^0.8.0;\nimport \"./Base.sol\";\nimport \"lib/ERC20.sol\";\nimport \"./Missing.sol\";",
+		"src/Base.sol":           "pragma solidity >=0.8.4;",
+		"third_party/ERC20.sol":  "pragma solidity ^0.8.20;",
+		"third_party/Unused.sol": "pragma solidity ^0.7.0;",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	resolver := importgraph.NewResolver([]importgraph.Remapping{{Prefix: "lib/", Target: "third_party/"}})
+	got, err := Collect([]string{"src/Token.sol"}, resolver)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+
+	var names []string
+	for _, fc := range got {
+		names = append(names, fc.String())
+	}
+	want := "src/Token.sol (^0.8.0), src/Base.sol (>=0.8.4), third_party/ERC20.sol (^0.8.20)"
+	if strings.Join(names, ", ") != want {
+		t.Errorf("expected %s, got %s", want, strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,495 @@
+// Package solparse provides a lightweight lexer and declaration parser for Solidity sources.
+//
+// It does not attempt to build a full AST. It tokenises a source file well enough to
+// skip comments and string literals, and extracts the top-level declarations that the
+// build rules care about (contracts, interfaces and libraries) along with import
+// directives.
+package solparse
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TokenKind classifies a lexical token.
+type TokenKind int
+
+const (
+	// EOF marks the end of the input.
+	EOF TokenKind = iota
+	// Ident is an identifier or keyword.
+	Ident
+	// Number is a numeric literal.
+	Number
+	// String is a string literal; Text holds the unquoted, unescaped value.
+	String
+	// Punct is any other single character.
+	Punct
+)
+
+// Token is a single lexical token.
+type Token struct {
+	Kind   TokenKind
+	Text   string
+	Line   int
+	Col    int
+	Offset int // Byte offset of the start of the token
+}
+
+// Lexer splits Solidity source into tokens, discarding whitespace and comments.
+type Lexer struct {
+	src  []byte
+	pos  int
+	line int
+	col  int
+}
+
+// NewLexer creates a new Lexer over the given source.
+func NewLexer(src []byte) *Lexer {
+	return &Lexer{src: src, line: 1, col: 1}
+}
+
+// Next returns the next token in the input.
+func (l *Lexer) Next() (Token, error) {
+	if err := l.skipSpaceAndComments(); err != nil {
+		return Token{}, err
+	}
+	if l.pos >= len(l.src) {
+		return Token{Kind: EOF, Line: l.line, Col: l.col, Offset: l.pos}, nil
+	}
+
+	line, col, offset := l.line, l.col, l.pos
+	c := l.src[l.pos]
+	switch {
+	case isIdentStart(c):
+		start := l.pos
+		for l.pos < len(l.src) && isIdentPart(l.src[l.pos]) {
+			l.advance()
+		}
+		return Token{Kind: Ident, Text: string(l.src[start:l.pos]), Line: line, Col: col, Offset: offset}, nil
+	case isDigit(c):
+		start := l.pos
+		for l.pos < len(l.src) && (isIdentPart(l.src[l.pos]) || l.src[l.pos] == '.') {
+			l.advance()
+		}
+		return Token{Kind: Number, Text: string(l.src[start:l.pos]), Line: line, Col: col, Offset: offset}, nil
+	case c == '"' || c == '\'':
+		text, err := l.readString(c)
+		if err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: String, Text: text, Line: line, Col: col, Offset: offset}, nil
+	default:
+		l.advance()
+		return Token{Kind: Punct, Text: string(c), Line: line, Col: col, Offset: offset}, nil
+	}
+}
+
+// advance moves forward one byte, tracking line and column.
+func (l *Lexer) advance() {
+	if l.src[l.pos] == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	l.pos++
+}
+
+// skipSpaceAndComments skips whitespace, line comments and block comments.
+func (l *Lexer) skipSpaceAndComments() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			l.advance()
+		case c == '/' && l.peek(1) == '/':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance()
+			}
+		case c == '/' && l.peek(1) == '*':
+			line, col := l.line, l.col
+			l.advance()
+			l.advance()
+			for {
+				if l.pos >= len(l.src) {
+					return fmt.Errorf("%d:%d: unterminated block comment", line, col)
+				}
+				if l.src[l.pos] == '*' && l.peek(1) == '/' {
+					l.advance()
+					l.advance()
+					break
+				}
+				l.advance()
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// readString reads a quoted string literal starting at the current position.
+func (l *Lexer) readString(quote byte) (string, error) {
+	line, col := l.line, l.col
+	l.advance()
+	var buf []byte
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+			return "", fmt.Errorf("%d:%d: unterminated string literal", line, col)
+		}
+		c := l.src[l.pos]
+		if c == quote {
+			l.advance()
+			return string(buf), nil
+		}
+		if c == '\\' && l.pos+1 < len(l.src) {
+			l.advance()
+			c = l.src[l.pos]
+		}
+		buf = append(buf, c)
+		l.advance()
+	}
+}
+
+func (l *Lexer) peek(n int) byte {
+	if l.pos+n < len(l.src) {
+		return l.src[l.pos+n]
+	}
+	return 0
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Contract kinds as reported by Parse.
+const (
+	KindContract  = "contract"
+	KindAbstract  = "abstract"
+	KindInterface = "interface"
+	KindLibrary   = "library"
+)
+
+// Contract is a top-level contract, interface or library declaration.
+type Contract struct {
+	Name string
+	Kind string
+	Line int
+}
+
+// Pragma is a pragma directive, e.g. `pragma solidity ^0.8.20;`.
+type Pragma struct {
+	Name  string
+	Value string // The raw text between the name and the semicolon
+	Line  int
+}
+
+// Symbol is a single name imported with the `import {A, B as C} from "..."` form.
+type Symbol struct {
+	Name  string `json:"name"`
+	Alias string `json:"alias,omitempty"`
+}
+
+// Import is an import directive.
+//
+// Path is the literal path as written in the source. Alias is set for
+// `import "path" as X` and `import * as X from "path"`, and Symbols for the
+// `import {A, B as C} from "path"` form.
+type Import struct {
+	Path    string   `json:"path"`
+	Alias   string   `json:"alias,omitempty"`
+	Symbols []Symbol `json:"symbols,omitempty"`
+	Line    int      `json:"line"`
+}
+
+// SourceUnit holds the declarations extracted from a single Solidity file.
+type SourceUnit struct {
+	Contracts []Contract
+	Imports   []Import
+	Pragmas   []Pragma
+}
+
+// SolidityVersions returns the version constraints of all `pragma solidity` directives.
+func (u *SourceUnit) SolidityVersions() []string {
+	var versions []string
+	for _, p := range u.Pragmas {
+		if p.Name == "solidity" {
+			versions = append(versions, p.Value)
+		}
+	}
+	return versions
+}
+
+// ParseFile parses the Solidity file at the given path.
+func ParseFile(path string) (*SourceUnit, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	unit, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", path, err)
+	}
+	return unit, nil
+}
+
+// Parse extracts top-level declarations from Solidity source.
+func Parse(src []byte) (*SourceUnit, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	unit := &SourceUnit{}
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.Kind == Punct {
+			switch tok.Text {
+			case "{", "(", "[":
+				depth++
+			case "}", ")", "]":
+				depth--
+			}
+			continue
+		}
+		if depth != 0 || tok.Kind != Ident {
+			continue
+		}
+
+		if tok.Text == "pragma" {
+			pragma, next, err := parsePragma(src, tokens, i)
+			if err != nil {
+				return nil, err
+			}
+			unit.Pragmas = append(unit.Pragmas, pragma)
+			i = next
+			continue
+		}
+
+		if tok.Text == "import" {
+			imp, next, err := parseImport(tokens, i)
+			if err != nil {
+				return nil, err
+			}
+			unit.Imports = append(unit.Imports, imp)
+			i = next
+			continue
+		}
+
+		kind := ""
+		switch tok.Text {
+		case "contract", "interface", "library":
+			kind = tok.Text
+		case "abstract":
+			if i+1 < len(tokens) && isIdent(tokens[i+1], "contract") {
+				kind = KindAbstract
+				i++
+			}
+		}
+		if kind == "" {
+			continue
+		}
+		if i+1 >= len(tokens) || tokens[i+1].Kind != Ident {
+			return nil, fmt.Errorf("%d:%d: expected name after %q", tok.Line, tok.Col, tokens[i].Text)
+		}
+		i++
+		unit.Contracts = append(unit.Contracts, Contract{Name: tokens[i].Text, Kind: kind, Line: tok.Line})
+	}
+	return unit, nil
+}
+
+// parsePragma parses a pragma directive starting at tokens[start] and returns it
+// along with the index of its terminating semicolon. Pragma values are free-form
+// text (e.g. ">=0.8.4 <0.9.0"), so they are sliced from the source rather than
+// reassembled from tokens.
+func parsePragma(src []byte, tokens []Token, start int) (Pragma, int, error) {
+	if start+1 >= len(tokens) || tokens[start+1].Kind != Ident {
+		return Pragma{}, 0, fmt.Errorf("%d:%d: expected name after pragma", tokens[start].Line, tokens[start].Col)
+	}
+	name := tokens[start+1]
+	for i := start + 2; i < len(tokens); i++ {
+		if tokens[i].Kind == Punct && tokens[i].Text == ";" {
+			value := string(src[name.Offset+len(name.Text) : tokens[i].Offset])
+			return Pragma{Name: name.Text, Value: strings.TrimSpace(value), Line: tokens[start].Line}, i, nil
+		}
+	}
+	return Pragma{}, 0, fmt.Errorf("%d:%d: unterminated pragma", tokens[start].Line, tokens[start].Col)
+}
+
+// parseImport parses an import directive starting at tokens[start] and returns it
+// along with the index of its terminating semicolon.
+func parseImport(tokens []Token, start int) (Import, int, error) {
+	imp := Import{Line: tokens[start].Line}
+	i := start + 1
+	expect := func(what string) (Token, error) {
+		if i >= len(tokens) {
+			return Token{}, fmt.Errorf("%d:%d: unexpected end of file in import, expected %s", tokens[start].Line, tokens[start].Col, what)
+		}
+		tok := tokens[i]
+		i++
+		return tok, nil
+	}
+	fail := func(tok Token, what string) error {
+		return fmt.Errorf("%d:%d: expected %s in import, got %q", tok.Line, tok.Col, what, tok.Text)
+	}
+
+	tok, err := expect("path")
+	if err != nil {
+		return imp, 0, err
+	}
+	switch {
+	case tok.Kind == String:
+		// import "path" [as X];
+		imp.Path = tok.Text
+		if i < len(tokens) && isIdent(tokens[i], "as") {
+			i++
+			alias, err := expect("alias")
+			if err != nil {
+				return imp, 0, err
+			}
+			if alias.Kind != Ident {
+				return imp, 0, fail(alias, "alias")
+			}
+			imp.Alias = alias.Text
+		}
+	case tok.Kind == Punct && tok.Text == "*":
+		// import * as X from "path";
+		as, err := expect("as")
+		if err != nil {
+			return imp, 0, err
+		}
+		if !isIdent(as, "as") {
+			return imp, 0, fail(as, `"as"`)
+		}
+		alias, err := expect("alias")
+		if err != nil {
+			return imp, 0, err
+		}
+		if alias.Kind != Ident {
+			return imp, 0, fail(alias, "alias")
+		}
+		imp.Alias = alias.Text
+	case tok.Kind == Punct && tok.Text == "{":
+		// import {A, B as C} from "path";
+		for {
+			name, err := expect("symbol")
+			if err != nil {
+				return imp, 0, err
+			}
+			if name.Kind == Punct && name.Text == "}" {
+				break
+			}
+			if name.Kind == Punct && name.Text == "," {
+				continue
+			}
+			if name.Kind != Ident {
+				return imp, 0, fail(name, "symbol")
+			}
+			sym := Symbol{Name: name.Text}
+			if i < len(tokens) && isIdent(tokens[i], "as") {
+				i++
+				alias, err := expect("alias")
+				if err != nil {
+					return imp, 0, err
+				}
+				if alias.Kind != Ident {
+					return imp, 0, fail(alias, "alias")
+				}
+				sym.Alias = alias.Text
+			}
+			imp.Symbols = append(imp.Symbols, sym)
+		}
+	default:
+		return imp, 0, fail(tok, "path")
+	}
+
+	if imp.Path == "" {
+		from, err := expect(`"from"`)
+		if err != nil {
+			return imp, 0, err
+		}
+		if !isIdent(from, "from") {
+			return imp, 0, fail(from, `"from"`)
+		}
+		path, err := expect("path")
+		if err != nil {
+			return imp, 0, err
+		}
+		if path.Kind != String {
+			return imp, 0, fail(path, "path")
+		}
+		imp.Path = path.Text
+	}
+
+	semi, err := expect(`";"`)
+	if err != nil {
+		return imp, 0, err
+	}
+	if semi.Kind != Punct || semi.Text != ";" {
+		return imp, 0, fail(semi, `";"`)
+	}
+	return imp, i - 1, nil
+}
+
+// FindSources expands the given paths into a sorted list of .sol files.
+func FindSources(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", p, err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".sol") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %w", p, err)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// tokenize lexes the whole source, excluding the trailing EOF token.
+func tokenize(src []byte) ([]Token, error) {
+	lexer := NewLexer(src)
+	var tokens []Token
+	for {
+		tok, err := lexer.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.Kind == EOF {
+			return tokens, nil
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+func isIdent(tok Token, text string) bool {
+	return tok.Kind == Ident && tok.Text == text
+}
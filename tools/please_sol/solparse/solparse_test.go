@@ -0,0 +1,216 @@
+package solparse
+
+import (
+	"testing"
+)
+
+func TestLexer_SkipsCommentsAndStrings(t *testing.T) {
+	src := `// contract Fake {
+/* interface AlsoFake {
+*/
+string constant s = "contract InString {";
+contract Real {}`
+
+	tokens, err := tokenize([]byte(src))
+	if err != nil {
+		t.Fatalf("tokenize failed: %v", err)
+	}
+
+	var idents []string
+	for _, tok := range tokens {
+		if tok.Kind == Ident {
+			idents = append(idents, tok.Text)
+		}
+	}
+	want := []string{"string", "constant", "s", "contract", "Real"}
+	if len(idents) != len(want) {
+		t.Fatalf("expected idents %v, got %v", want, idents)
+	}
+	for i := range want {
+		if idents[i] != want[i] {
+			t.Errorf("ident %d: expected %q, got %q", i, want[i], idents[i])
+		}
+	}
+}
+
+func TestLexer_StringEscapes(t *testing.T) {
+	tokens, err := tokenize([]byte(`'it\'s' "a\"b"`))
+	if err != nil {
+		t.Fatalf("tokenize failed: %v", err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("expected 2 tokens, got %d", len(tokens))
+	}
+	if tokens[0].Text != "it's" {
+		t.Errorf("expected it's, got %q", tokens[0].Text)
+	}
+	if tokens[1].Text != `a"b` {
+		t.Errorf(`expected a"b, got %q`, tokens[1].Text)
+	}
+}
+
+func TestLexer_Positions(t *testing.T) {
+	tokens, err := tokenize([]byte("pragma\n  solidity"))
+	if err != nil {
+		t.Fatalf("tokenize failed: %v", err)
+	}
+	if tokens[1].Line != 2 || tokens[1].Col != 3 {
+		t.Errorf("expected 2:3, got %d:%d", tokens[1].Line, tokens[1].Col)
+	}
+}
+
+func TestLexer_Unterminated(t *testing.T) {
+	for _, src := range []string{`"abc`, `/* abc`} {
+		if _, err := tokenize([]byte(src)); err == nil {
+			t.Errorf("expected error for %q", src)
+		}
+	}
+}
+
+func TestParse_Contracts(t *testing.T) {
+	src := `// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.20;
+
+interface IToken {
+    function transfer(address to, uint256 amount) external returns (bool);
+}
+
+abstract contract Base
+{
+    function process() public virtual returns (uint256);
+}
+
+library MathLib {
+    function add(uint256 a, uint256 b) internal pure returns (uint256) {
+        return a + b;
+    }
+}
+
+contract Token is Base, IToken {
+    string public name = "contract Hidden {";
+
+    function process() public pure override returns (uint256) {
+        return 1;
+    }
+}
+`
+	unit, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	want := []Contract{
+		{Name: "IToken", Kind: KindInterface, Line: 4},
+		{Name: "Base", Kind: KindAbstract, Line: 8},
+		{Name: "MathLib", Kind: KindLibrary, Line: 13},
+		{Name: "Token", Kind: KindContract, Line: 19},
+	}
+	if len(unit.Contracts) != len(want) {
+		t.Fatalf("expected %d contracts, got %d: %v", len(want), len(unit.Contracts), unit.Contracts)
+	}
+	for i, c := range want {
+		if unit.Contracts[i] != c {
+			t.Errorf("contract %d: expected %+v, got %+v", i, c, unit.Contracts[i])
+		}
+	}
+}
+
+func TestParse_MissingName(t *testing.T) {
+	if _, err := Parse([]byte("contract {}")); err == nil {
+		t.Error("expected error for contract without a name")
+	}
+}
+
+func TestParse_Imports(t *testing.T) {
+	src := `pragma solidity ^0.8.20;
+
+import "./Helper.sol";
+import "forge-std/Test.sol" as T;
+import * as Lib from "solmate/utils/Lib.sol";
+import {ERC20, IERC20 as Token} from
+    "@openzeppelin/contracts/token/ERC20/ERC20.sol";
+// import "commented/Out.sol";
+
+contract C {
+    string s = "import \"not/an/import.sol\";";
+}
+`
+	unit, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if len(unit.Imports) != 4 {
+		t.Fatalf("expected 4 imports, got %d: %+v", len(unit.Imports), unit.Imports)
+	}
+
+	plain := unit.Imports[0]
+	if plain.Path != "./Helper.sol" || plain.Alias != "" || plain.Line != 3 {
+		t.Errorf("unexpected plain import: %+v", plain)
+	}
+
+	aliased := unit.Imports[1]
+	if aliased.Path != "forge-std/Test.sol" || aliased.Alias != "T" {
+		t.Errorf("unexpected aliased import: %+v", aliased)
+	}
+
+	star := unit.Imports[2]
+	if star.Path != "solmate/utils/Lib.sol" || star.Alias != "Lib" {
+		t.Errorf("unexpected star import: %+v", star)
+	}
+
+	symbols := unit.Imports[3]
+	if symbols.Path != "@openzeppelin/contracts/token/ERC20/ERC20.sol" {
+		t.Errorf("unexpected path: %q", symbols.Path)
+	}
+	want := []Symbol{{Name: "ERC20"}, {Name: "IERC20", Alias: "Token"}}
+	if len(symbols.Symbols) != len(want) {
+		t.Fatalf("expected symbols %v, got %v", want, symbols.Symbols)
+	}
+	for i := range want {
+		if symbols.Symbols[i] != want[i] {
+			t.Errorf("symbol %d: expected %+v, got %+v", i, want[i], symbols.Symbols[i])
+		}
+	}
+}
+
+func TestParse_MalformedImports(t *testing.T) {
+	for _, src := range []string{
+		`import "a.sol"`,
+		`import {A} "a.sol";`,
+		`import * from "a.sol";`,
+		`import 42;`,
+	} {
+		if _, err := Parse([]byte(src)); err == nil {
+			t.Errorf("expected error for %q", src)
+		}
+	}
+}
+
+func TestParse_Pragmas(t *testing.T) {
+	src := `// SPDX-License-Identifier: MIT
+pragma solidity >=0.8.4  <0.9.0;
+pragma abicoder v2;
+pragma solidity ^0.8.20;
+`
+	unit, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if len(unit.Pragmas) != 3 {
+		t.Fatalf("expected 3 pragmas, got %+v", unit.Pragmas)
+	}
+	if unit.Pragmas[1].Name != "abicoder" || unit.Pragmas[1].Value != "v2" {
+		t.Errorf("unexpected abicoder pragma: %+v", unit.Pragmas[1])
+	}
+
+	versions := unit.SolidityVersions()
+	if len(versions) != 2 || versions[0] != ">=0.8.4  <0.9.0" || versions[1] != "^0.8.20" {
+		t.Errorf("unexpected solidity versions: %q", versions)
+	}
+
+	if _, err := Parse([]byte("pragma solidity ^0.8.0")); err == nil {
+		t.Error("expected error for unterminated pragma")
+	}
+}
@@ -0,0 +1,427 @@
+// Package testreport converts the results of `forge test --json` into the JUnit
+// XML that Please reads from a test's results file, so that individual Solidity
+// tests show up in plz test and CI.
+package testreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of a test.
+type Status string
+
+const (
+	StatusSuccess Status = "Success"
+	StatusFailure Status = "Failure"
+	StatusSkipped Status = "Skipped"
+)
+
+// Report is the results of a forge test run.
+type Report struct {
+	// Suites are sorted by name.
+	Suites []Suite
+}
+
+// Suite is the results of one test contract, named "path/File.t.sol:Contract".
+type Suite struct {
+	Name     string
+	Duration time.Duration
+	// Tests are sorted by name.
+	Tests    []Test
+	Warnings []string
+}
+
+// Test is the result of one test function.
+type Test struct {
+	// Name is the function's signature, e.g. "testFuzz_Increment(uint256)".
+	Name   string
+	Status Status
+	Reason string
+	// Counterexample is the input a fuzz or invariant test failed with, as forge prints it.
+	Counterexample string
+	// Calls are the counterexample's calls: one for a fuzz test, or an invariant test's sequence.
+	Calls    []Call
+	Duration time.Duration
+	// Kind is "unit", "fuzz" or "invariant".
+	Kind string
+	// Gas is the gas a unit test used, or the mean over a fuzz test's runs.
+	Gas uint64
+	// Runs is how many runs a fuzz or invariant test made.
+	Runs int
+	Logs []string
+}
+
+// Call is one call in a counterexample.
+type Call struct {
+	Sender    string `json:"sender,omitempty"`
+	Addr      string `json:"addr,omitempty"`
+	Calldata  string `json:"calldata,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Args      string `json:"args,omitempty"`
+}
+
+// Contract returns the suite's contract name.
+func (s *Suite) Contract() string {
+	if i := strings.LastIndex(s.Name, ":"); i >= 0 {
+		return s.Name[i+1:]
+	}
+	return s.Name
+}
+
+// Failed returns true if any test failed.
+func (r *Report) Failed() bool {
+	for _, s := range r.Suites {
+		for _, t := range s.Tests {
+			if t.Status == StatusFailure {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ExitCode returns the exit code for a test run in which forge exited with
+// forgeExitCode: forge's own if it failed, otherwise 1 if any test failed.
+func (r *Report) ExitCode(forgeExitCode int) int {
+	if forgeExitCode == 0 && r.Failed() {
+		return 1
+	}
+	return forgeExitCode
+}
+
+// Read returns forge test --json output, along with the exit code of the command
+// that produced it. If there's a command, it's run with stdin and stderr passed
+// through and its stdout is the output; otherwise the output is read from the
+// input file, or from stdin if input is empty.
+func Read(command []string, input string, stdin io.Reader, stderr io.Writer) ([]byte, int, error) {
+	if len(command) > 0 {
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdin, cmd.Stderr = stdin, stderr
+		output, err := cmd.Output()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return output, exitErr.ExitCode(), nil
+		} else if err != nil {
+			return nil, 0, fmt.Errorf("failed to run %s: %w", command[0], err)
+		}
+		return output, 0, nil
+	}
+	var output []byte
+	var err error
+	if input != "" {
+		output, err = os.ReadFile(input)
+	} else {
+		output, err = io.ReadAll(stdin)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read test results: %w", err)
+	}
+	return output, 0, nil
+}
+
+// forgeSuite and forgeTest are the parts of forge's JSON results used here.
+type forgeSuite struct {
+	Duration    duration             `json:"duration"`
+	TestResults map[string]forgeTest `json:"test_results"`
+	Warnings    []string             `json:"warnings"`
+}
+
+type forgeTest struct {
+	Status         Status                     `json:"status"`
+	Reason         *string                    `json:"reason"`
+	Counterexample json.RawMessage            `json:"counterexample"`
+	DecodedLogs    []string                   `json:"decoded_logs"`
+	Kind           map[string]json.RawMessage `json:"kind"`
+	Duration       duration                   `json:"duration"`
+}
+
+type forgeKind struct {
+	Gas     uint64 `json:"gas"`
+	Runs    int    `json:"runs"`
+	MeanGas uint64 `json:"mean_gas"`
+}
+
+// duration is a Rust Duration as forge serialises it: either {"secs", "nanos"}
+// or a human-readable string such as "1s 234ms 5µs".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := time.ParseDuration(strings.ReplaceAll(s, " ", ""))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", s, err)
+		}
+		*d = duration(parsed)
+		return nil
+	}
+	var v struct {
+		Secs  int64 `json:"secs"`
+		Nanos int64 `json:"nanos"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	*d = duration(time.Duration(v.Secs)*time.Second + time.Duration(v.Nanos))
+	return nil
+}
+
+// Parse reads forge test --json output. Anything before the JSON document, such
+// as compiler output from older versions of forge, is skipped.
+func Parse(data []byte) (*Report, error) {
+	start := 0
+	if !bytes.HasPrefix(data, []byte("{")) {
+		if start = bytes.Index(data, []byte("\n{")); start < 0 {
+			return nil, fmt.Errorf("no test results found in forge output")
+		}
+		start++
+	}
+	var suites map[string]forgeSuite
+	if err := json.NewDecoder(bytes.NewReader(data[start:])).Decode(&suites); err != nil {
+		return nil, fmt.Errorf("failed to parse forge test results: %w", err)
+	}
+
+	report := &Report{}
+	for name, fs := range suites {
+		suite := Suite{Name: name, Duration: time.Duration(fs.Duration), Warnings: fs.Warnings}
+		for testName, ft := range fs.TestResults {
+			suite.Tests = append(suite.Tests, ft.test(testName))
+		}
+		sort.Slice(suite.Tests, func(i, j int) bool { return suite.Tests[i].Name < suite.Tests[j].Name })
+		report.Suites = append(report.Suites, suite)
+	}
+	sort.Slice(report.Suites, func(i, j int) bool { return report.Suites[i].Name < report.Suites[j].Name })
+	return report, nil
+}
+
+func (ft forgeTest) test(name string) Test {
+	t := Test{
+		Name:     name,
+		Status:   ft.Status,
+		Duration: time.Duration(ft.Duration),
+		Calls:    parseCounterexample(ft.Counterexample),
+		Logs:     ft.DecodedLogs,
+	}
+	t.Counterexample = formatCalls(t.Calls)
+	if ft.Reason != nil {
+		t.Reason = *ft.Reason
+	}
+	for kind, data := range ft.Kind {
+		var k forgeKind
+		json.Unmarshal(data, &k)
+		t.Kind = strings.ToLower(kind)
+		if t.Gas, t.Runs = k.Gas, k.Runs; k.MeanGas != 0 {
+			t.Gas = k.MeanGas
+		}
+	}
+	return t
+}
+
+// parseCounterexample returns the calls in one of forge's counterexamples,
+// which is either {"Single": call} or {"Sequence": [call, ...]}.
+func parseCounterexample(data json.RawMessage) []Call {
+	var example struct {
+		Single   *Call  `json:"Single"`
+		Sequence []Call `json:"Sequence"`
+	}
+	if len(data) == 0 || json.Unmarshal(data, &example) != nil {
+		return nil
+	}
+	if example.Single != nil {
+		return []Call{*example.Single}
+	}
+	return example.Sequence
+}
+
+// formatCalls renders a counterexample like forge does, e.g.
+// "calldata=0x... args=[1]". Each call in an invariant test's sequence gets a line.
+func formatCalls(calls []Call) string {
+	var lines []string
+	for _, call := range calls {
+		var fields []string
+		for _, field := range [][2]string{
+			{"sender", call.Sender}, {"addr", call.Addr}, {"calldata", call.Calldata}, {"args", call.Args},
+		} {
+			if field[1] != "" {
+				fields = append(fields, field[0]+"="+field[1])
+			}
+		}
+		lines = append(lines, strings.Join(fields, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WriteSummary writes a human-readable summary of the results in the style of
+// forge's own output.
+func (r *Report) WriteSummary(w io.Writer) error {
+	var b strings.Builder
+	var passed, failed, skipped int
+	for _, s := range r.Suites {
+		fmt.Fprintf(&b, "Ran %d test%s for %s\n", len(s.Tests), plural(len(s.Tests)), s.Name)
+		for _, t := range s.Tests {
+			switch t.Status {
+			case StatusSuccess:
+				passed++
+				fmt.Fprintf(&b, "[PASS] %s (%s)\n", t.Name, t.cost())
+			case StatusSkipped:
+				skipped++
+				fmt.Fprintf(&b, "[SKIP] %s\n", t.Name)
+			default:
+				failed++
+				fmt.Fprintf(&b, "[FAIL: %s] %s (%s)\n", t.failure(), t.Name, t.cost())
+				for _, log := range t.Logs {
+					fmt.Fprintf(&b, "    %s\n", log)
+				}
+			}
+		}
+		for _, warning := range s.Warnings {
+			fmt.Fprintf(&b, "Warning: %s\n", warning)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cost describes the gas a test used, as forge does.
+func (t *Test) cost() string {
+	switch t.Kind {
+	case "fuzz":
+		return fmt.Sprintf("runs: %d, μ: %d", t.Runs, t.Gas)
+	case "invariant":
+		return fmt.Sprintf("runs: %d", t.Runs)
+	default:
+		return fmt.Sprintf("gas: %d", t.Gas)
+	}
+}
+
+// failure describes why a test failed, with its counterexample.
+func (t *Test) failure() string {
+	reason := t.Reason
+	if reason == "" {
+		reason = "test failed"
+	}
+	if t.Counterexample != "" {
+		reason += "; counterexample: " + strings.ReplaceAll(t.Counterexample, "\n", "; ")
+	}
+	return reason
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// junitSuites and the types below are the JUnit XML schema Please reads.
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name       string          `xml:"name,attr"`
+	Classname  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitFailure   `xml:"failure,omitempty"`
+	Skipped    *struct{}       `xml:"skipped,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as JUnit XML. Each test records its gas and,
+// for fuzz and invariant tests, its runs as properties.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	doc := junitSuites{}
+	for _, s := range r.Suites {
+		suite := junitSuite{Name: s.Name, Tests: len(s.Tests), Time: seconds(s.Duration)}
+		for _, t := range s.Tests {
+			c := junitCase{Name: t.Name, Classname: s.Contract(), Time: seconds(t.Duration)}
+			if t.Kind != "invariant" {
+				c.Properties = append(c.Properties, junitProperty{Name: "gas", Value: fmt.Sprint(t.Gas)})
+			}
+			if t.Kind == "fuzz" || t.Kind == "invariant" {
+				c.Properties = append(c.Properties, junitProperty{Name: "runs", Value: fmt.Sprint(t.Runs)})
+			}
+			switch t.Status {
+			case StatusFailure:
+				suite.Failures++
+				body := t.Reason
+				if t.Counterexample != "" {
+					body += "\nCounterexample:\n" + t.Counterexample
+				}
+				c.Failure = &junitFailure{Message: t.failure(), Type: "Failure", Body: strings.TrimSpace(body)}
+			case StatusSkipped:
+				suite.Skipped++
+				c.Skipped = &struct{}{}
+			}
+			c.SystemOut = strings.Join(t.Logs, "\n")
+			suite.Cases = append(suite.Cases, c)
+		}
+		doc.Suites = append(doc.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write JUnit XML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteFile writes the results as JUnit XML to a file, such as the test's $RESULTS_FILE.
+func (r *Report) WriteFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create results file: %w", err)
+	}
+	if err := r.WriteJUnit(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write results file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write results file: %w", err)
+	}
+	return nil
+}
+
+// seconds formats a duration as JUnit's decimal seconds.
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
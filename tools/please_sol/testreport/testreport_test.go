@@ -0,0 +1,218 @@
+package testreport
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// forgeOutput is trimmed from forge test --json.
+const forgeOutput = `Compiling 3 files with Solc 0.8.20
+{"test/Counter.t.sol:CounterTest":{"duration":"2ms 345us","test_results":{
+"test_Increment()":{"status":"Success","reason":null,"counterexample":null,"decoded_logs":[],"kind":{"Unit":{"gas":31303}},"duration":{"secs":0,"nanos":1500000}},
+"testFuzz_SetNumber(uint256)":{"status":"Failure","reason":"assertion failed: 1 != 2","counterexample":{"Single":{"sender":null,"addr":null,"calldata":"0x3fb5c1cb0000000000000000000000000000000000000000000000000000000000000001","signature":"setNumber(uint256)","args":"1"}},"decoded_logs":["number: 1"],"kind":{"Fuzz":{"first_case":{},"runs":3,"mean_gas":28000,"median_gas":27900}},"duration":{"secs":0,"nanos":900000}},
+"test_Skipped()":{"status":"Skipped","reason":null,"counterexample":null,"decoded_logs":[],"kind":{"Unit":{"gas":0}},"duration":{"secs":0,"nanos":0}}
+},"warnings":[]},
+"test/Invariant.t.sol:InvariantTest":{"duration":{"secs":1,"nanos":0},"test_results":{
+"invariant_Total()":{"status":"Failure","reason":"revert: total","counterexample":{"Sequence":[{"sender":"0x01","addr":"0x02","calldata":"0xaa","args":"5"},{"sender":"0x01","addr":"0x02","calldata":"0xbb","args":""}]},"decoded_logs":[],"kind":{"Invariant":{"runs":256,"calls":128000,"reverts":3}},"duration":{"secs":1,"nanos":0}}
+},"warnings":["no invariants"]}}
+`
+
+func TestParse(t *testing.T) {
+	report, err := Parse([]byte(forgeOutput))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(report.Suites) != 2 {
+		t.Fatalf("expected 2 suites, got %d", len(report.Suites))
+	}
+	suite := report.Suites[0]
+	if suite.Name != "test/Counter.t.sol:CounterTest" || suite.Contract() != "CounterTest" {
+		t.Errorf("unexpected suite %q", suite.Name)
+	}
+	if suite.Duration != 2345*time.Microsecond {
+		t.Errorf("expected duration 2.345ms, got %s", suite.Duration)
+	}
+	if len(suite.Tests) != 3 {
+		t.Fatalf("expected 3 tests, got %d", len(suite.Tests))
+	}
+
+	fuzz := suite.Tests[0]
+	if fuzz.Name != "testFuzz_SetNumber(uint256)" || fuzz.Status != StatusFailure || fuzz.Kind != "fuzz" {
+		t.Errorf("unexpected fuzz test: %+v", fuzz)
+	}
+	if fuzz.Gas != 28000 || fuzz.Runs != 3 {
+		t.Errorf("expected mean gas 28000 over 3 runs, got %d over %d", fuzz.Gas, fuzz.Runs)
+	}
+	if fuzz.Counterexample != "calldata=0x3fb5c1cb0000000000000000000000000000000000000000000000000000000000000001 args=1" {
+		t.Errorf("unexpected counterexample %q", fuzz.Counterexample)
+	}
+	if unit := suite.Tests[1]; unit.Gas != 31303 || unit.Duration != 1500*time.Microsecond {
+		t.Errorf("unexpected unit test: %+v", unit)
+	}
+
+	invariant := report.Suites[1].Tests[0]
+	if len(invariant.Calls) != 2 || invariant.Calls[1] != (Call{Sender: "0x01", Addr: "0x02", Calldata: "0xbb"}) {
+		t.Errorf("unexpected calls %+v", invariant.Calls)
+	}
+	if invariant.Counterexample != "sender=0x01 addr=0x02 calldata=0xaa args=5\nsender=0x01 addr=0x02 calldata=0xbb" {
+		t.Errorf("unexpected sequence %q", invariant.Counterexample)
+	}
+	if !report.Failed() {
+		t.Error("expected the report to have failed")
+	}
+}
+
+func TestParse_NoResults(t *testing.T) {
+	if _, err := Parse([]byte("Error: Compiler run failed\n")); err == nil {
+		t.Error("expected an error for output without results")
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	report, err := Parse([]byte(forgeOutput))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var out strings.Builder
+	if err := report.WriteJUnit(&out); err != nil {
+		t.Fatalf("WriteJUnit failed: %v", err)
+	}
+
+	var doc junitSuites
+	if err := xml.Unmarshal([]byte(out.String()), &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out.String())
+	}
+	suite := doc.Suites[0]
+	if suite.Tests != 3 || suite.Failures != 1 || suite.Skipped != 1 || suite.Time != "0.002" {
+		t.Errorf("unexpected suite: %+v", suite)
+	}
+	fuzz := suite.Cases[0]
+	if fuzz.Classname != "CounterTest" || fuzz.Failure == nil {
+		t.Fatalf("unexpected fuzz case: %+v", fuzz)
+	}
+	if !strings.Contains(fuzz.Failure.Message, "assertion failed: 1 != 2; counterexample: calldata=0x3fb5") {
+		t.Errorf("expected the failure to have the reason and counterexample, got %q", fuzz.Failure.Message)
+	}
+	if fuzz.SystemOut != "number: 1" {
+		t.Errorf("expected the logs in system-out, got %q", fuzz.SystemOut)
+	}
+	if len(fuzz.Properties) != 2 || fuzz.Properties[0] != (junitProperty{Name: "gas", Value: "28000"}) {
+		t.Errorf("unexpected properties: %+v", fuzz.Properties)
+	}
+	if suite.Cases[2].Skipped == nil {
+		t.Errorf("expected %s to be skipped", suite.Cases[2].Name)
+	}
+	if strings.Contains(out.String(), "<properties></properties>") {
+		t.Errorf("expected no empty properties, got:\n%s", out.String())
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	report, err := Parse([]byte(forgeOutput))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "results.xml")
+	if err := report.WriteFile(out); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read results: %v", err)
+	}
+	var doc junitSuites
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, data)
+	}
+	if len(doc.Suites) != 2 || doc.Suites[1].Name != "test/Invariant.t.sol:InvariantTest" {
+		t.Errorf("unexpected suites: %+v", doc.Suites)
+	}
+
+	if err := report.WriteFile(filepath.Join(out, "results.xml")); err == nil {
+		t.Error("expected an error for an unwritable results file")
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "forge")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nread line\necho \"$line $1\"\necho oops >&2\nexit 3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var stderr strings.Builder
+	output, exitCode, err := Read([]string{script, "--json"}, "", strings.NewReader("results\n"), &stderr)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(output) != "results --json\n" || exitCode != 3 || stderr.String() != "oops\n" {
+		t.Errorf("unexpected output %q, exit code %d and stderr %q", output, exitCode, stderr.String())
+	}
+	if _, _, err := Read([]string{filepath.Join(dir, "missing")}, "", nil, nil); err == nil {
+		t.Error("expected an error for a command that can't be run")
+	}
+
+	input := filepath.Join(dir, "results.json")
+	if err := os.WriteFile(input, []byte(forgeOutput), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if output, exitCode, err := Read(nil, input, nil, nil); err != nil || string(output) != forgeOutput || exitCode != 0 {
+		t.Errorf("unexpected result reading %s: %d, %v", input, exitCode, err)
+	}
+	if output, _, err := Read(nil, "", strings.NewReader("stdin"), nil); err != nil || string(output) != "stdin" {
+		t.Errorf("expected to read stdin, got %q, %v", output, err)
+	}
+	if _, _, err := Read(nil, filepath.Join(dir, "missing.json"), nil, nil); err == nil {
+		t.Error("expected an error for a missing input file")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	failed, err := Parse([]byte(forgeOutput))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	passed := &Report{Suites: []Suite{{Name: "A", Tests: []Test{{Name: "test_A()", Status: StatusSuccess}}}}}
+	tests := []struct {
+		report   *Report
+		forge    int
+		expected int
+	}{
+		{passed, 0, 0},
+		{failed, 0, 1},
+		{failed, 2, 2},
+		{passed, 2, 2},
+	}
+	for _, test := range tests {
+		if code := test.report.ExitCode(test.forge); code != test.expected {
+			t.Errorf("expected exit code %d for forge's %d, got %d", test.expected, test.forge, code)
+		}
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	report, err := Parse([]byte(forgeOutput))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var out strings.Builder
+	if err := report.WriteSummary(&out); err != nil {
+		t.Fatalf("WriteSummary failed: %v", err)
+	}
+	for _, want := range []string{
+		"Ran 3 tests for test/Counter.t.sol:CounterTest\n",
+		"[PASS] test_Increment() (gas: 31303)\n",
+		"[FAIL: assertion failed: 1 != 2; counterexample: calldata=0x3fb5",
+		"] testFuzz_SetNumber(uint256) (runs: 3, μ: 28000)\n    number: 1\n",
+		"[SKIP] test_Skipped()\n",
+		"Warning: no invariants\n",
+		"1 passed, 2 failed, 1 skipped\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected summary to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package testrun runs forge test for a sol_test: it narrows forge to the tests
+// and shard selected, fuzzes with a seed so that failures can be replayed, and
+// writes the results as JUnit XML for Please.
+package testrun
+
+import (
+	"fmt"
+	"io"
+
+	"tools/please_sol/fuzzcorpus"
+	"tools/please_sol/listtests"
+	"tools/please_sol/testreport"
+)
+
+// Options configures a test run.
+type Options struct {
+	// Command runs forge test --json. If it's empty, the results are read from
+	// Input, or stdin if that's "", and nothing is narrowed or replayed.
+	Command []string
+	Input   string
+	// Out is the file to write JUnit XML results to, e.g. $RESULTS_FILE.
+	Out string
+	// Corpus, if set, is the file to save failed fuzz and invariant tests to.
+	Corpus string
+	// Tests are tests to run, as function names or Contract.name, e.g. $TESTS.
+	Tests []string
+	// TestArgs are the test's arguments, e.g. $TEST_ARGS. Leading words select
+	// tests as Tests do, and the rest are passed to forge.
+	TestArgs string
+	// Shard, if set, is the shard "INDEX/COUNT" of the tests in Artifacts to run.
+	Shard     string
+	Artifacts string
+	// Root is the directory forge persists fuzz failures under.
+	Root string
+}
+
+// Run runs the tests and writes their results to o.Out. It returns the exit code
+// the test should have: forge's, or 1 if forge passed but a test failed.
+func Run(o Options, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	selectors, rest := listtests.SplitArgs(o.TestArgs)
+	selectors = append(o.Tests[:len(o.Tests):len(o.Tests)], selectors...)
+
+	command := o.Command
+	var session *fuzzcorpus.Session
+	if len(command) > 0 {
+		command = append(command[:len(command):len(command)], rest...)
+	}
+	if o.Corpus != "" && len(command) == 0 {
+		session = &fuzzcorpus.Session{}
+	} else if o.Corpus != "" {
+		// Fuzz with a known seed so the failures can be replayed, or replay a corpus.
+		var err error
+		session, command, err = fuzzcorpus.Start(command, o.Root)
+		if err != nil {
+			return 0, err
+		}
+		if session.Replayed != nil {
+			fmt.Fprintf(stdout, "Replaying %d failed fuzz test(s) from %s with seed %s\n", len(session.Replayed.Failures), session.Replay, session.Seed)
+		}
+	}
+	// Narrow forge to the selected tests in this shard. A replay selects its own.
+	if (len(selectors) > 0 || o.Shard != "") && len(command) > 0 && (session == nil || session.Replayed == nil) {
+		args, ok, err := listtests.Narrow(selectors, o.Shard, o.Artifacts)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			fmt.Fprintf(stdout, "No tests to run in shard %s\n", o.Shard)
+			if session != nil {
+				if _, err := session.Save(nil, o.Root, o.Corpus); err != nil {
+					return 0, err
+				}
+			}
+			return 0, (&testreport.Report{}).WriteFile(o.Out)
+		}
+		command = append(command, args...)
+	}
+
+	output, exitCode, err := testreport.Read(command, o.Input, stdin, stderr)
+	if err != nil {
+		return 0, err
+	}
+	report, parseErr := testreport.Parse(output)
+	savedFailures := ""
+	if session != nil {
+		corpus, err := session.Save(report, o.Root, o.Corpus)
+		if err != nil {
+			return 0, err
+		}
+		savedFailures = corpus.Saved(o.Corpus)
+	}
+	if parseErr != nil {
+		// If the command failed without results (e.g. it didn't compile), it's
+		// already said why.
+		if exitCode != 0 {
+			stdout.Write(output)
+			return exitCode, nil
+		}
+		return 0, parseErr
+	}
+	if err := report.WriteSummary(stdout); err != nil {
+		return 0, fmt.Errorf("failed to write test summary: %w", err)
+	}
+	fmt.Fprint(stdout, savedFailures)
+	if err := report.WriteFile(o.Out); err != nil {
+		return 0, err
+	}
+	return report.ExitCode(exitCode), nil
+}
+
+// ForgeArgs returns the arguments for another forge command over the same tests,
+// such as forge coverage: filters for the tests selected by tests and testArgs
+// as for Run, and the rest of testArgs without any --replay, which only forge test
+// is given.
+func ForgeArgs(tests []string, testArgs string) []string {
+	selectors, rest := listtests.SplitArgs(testArgs)
+	_, rest = fuzzcorpus.CutReplay(rest)
+	return append(rest, listtests.FilterArgs(append(tests[:len(tests):len(tests)], selectors...))...)
+}
@@ -0,0 +1,127 @@
+package testrun
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tools/please_sol/fuzzcorpus"
+)
+
+const results = `{"test/Counter.t.sol:CounterTest":{"duration":"1ms","test_results":{
+"testFuzz_SetNumber(uint256)":{"status":"Success","reason":null,"counterexample":null,"decoded_logs":[],"kind":{"Fuzz":{"first_case":{},"runs":3,"mean_gas":100,"median_gas":100}},"duration":{"secs":0,"nanos":1000}}}}}`
+
+// setUp lays out a CounterTest artifact with three tests and a fake forge that
+// records its arguments in args.txt before printing results, and returns the
+// directory and the options to run it.
+func setUp(t *testing.T) (string, Options) {
+	dir := t.TempDir()
+	artifact := filepath.Join(dir, "out/Counter.t.sol/CounterTest.json")
+	os.MkdirAll(filepath.Dir(artifact), 0755)
+	os.WriteFile(artifact, []byte(`{"abi":[
+		{"type":"function","name":"invariant_Total"},
+		{"type":"function","name":"testFuzz_SetNumber"},
+		{"type":"function","name":"test_Increment"}
+	],"bytecode":{"object":"0x6080"}}`), 0644)
+	os.WriteFile(filepath.Join(dir, "results.json"), []byte(results), 0644)
+	forge := filepath.Join(dir, "forge")
+	script := "#!/bin/sh\necho \"$@\" > " + filepath.Join(dir, "args.txt") + "\ncat " + filepath.Join(dir, "results.json") + "\n"
+	if err := os.WriteFile(forge, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir, Options{
+		Command:   []string{forge, "test", "--json"},
+		Out:       filepath.Join(dir, "results.xml"),
+		Corpus:    filepath.Join(dir, "fuzz-failures.json"),
+		Artifacts: filepath.Join(dir, "out"),
+		Root:      dir,
+	}
+}
+
+func TestRun_NamesAndShard(t *testing.T) {
+	dir, o := setUp(t)
+	o.TestArgs = "test_Increment testFuzz_SetNumber -vvv"
+	o.Shard = "1/3"
+	var stdout strings.Builder
+	exitCode, err := Run(o, nil, &stdout, nil)
+	if err != nil || exitCode != 0 {
+		t.Fatalf("Run failed with exit code %d: %v", exitCode, err)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args.txt"))
+	// Of the named tests, only testFuzz_SetNumber is in the second of three shards.
+	if expected := "test --json -vvv --fuzz-seed "; !strings.HasPrefix(string(args), expected) ||
+		!strings.HasSuffix(string(args), " --match-test ^(testFuzz_SetNumber)$ --match-contract ^(CounterTest)$\n") {
+		t.Errorf("unexpected forge arguments %q", args)
+	}
+	if xml, _ := os.ReadFile(o.Out); !strings.Contains(string(xml), `name="testFuzz_SetNumber(uint256)"`) {
+		t.Errorf("expected the test's results in %s, got %s", o.Out, xml)
+	}
+}
+
+func TestRun_EmptyShard(t *testing.T) {
+	dir, o := setUp(t)
+	o.Tests = []string{"test_Increment"}
+	o.Shard = "0/3"
+	var stdout strings.Builder
+	exitCode, err := Run(o, nil, &stdout, nil)
+	if err != nil || exitCode != 0 {
+		t.Fatalf("Run failed with exit code %d: %v", exitCode, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "args.txt")); err == nil {
+		t.Error("expected forge not to run for an empty shard")
+	}
+	if stdout.String() != "No tests to run in shard 0/3\n" {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+	if xml, err := os.ReadFile(o.Out); err != nil || !strings.Contains(string(xml), "<testsuites") {
+		t.Errorf("expected empty results in %s, got %q (%v)", o.Out, xml, err)
+	}
+	if corpus, err := fuzzcorpus.Load(o.Corpus); err != nil || corpus.Seed == "" {
+		t.Errorf("expected the seed to be saved, got %+v (%v)", corpus, err)
+	}
+}
+
+func TestRun_Crash(t *testing.T) {
+	dir, o := setUp(t)
+	forge := filepath.Join(dir, "crash")
+	os.WriteFile(forge, []byte("#!/bin/sh\necho \"$@\" > "+filepath.Join(dir, "args.txt")+"\nexit 2\n"), 0755)
+	o.Command = []string{forge, "test", "--json"}
+	o.TestArgs = "--fuzz-seed=0x2a"
+	var stdout strings.Builder
+	if exitCode, err := Run(o, nil, &stdout, nil); err != nil || exitCode != 2 {
+		t.Errorf("expected forge's exit code 2, got %d (%v)", exitCode, err)
+	}
+	if corpus, err := fuzzcorpus.Load(o.Corpus); err != nil || corpus.Seed != "0x2a" {
+		t.Errorf("expected the seed to be saved, got %+v (%v)", corpus, err)
+	}
+
+	// A replay selects its own tests rather than the named ones.
+	os.Remove(o.Out)
+	o.TestArgs = "test_Increment --replay=" + o.Corpus
+	o.Corpus = filepath.Join(dir, "again.json")
+	if _, err := Run(o, nil, &stdout, nil); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if args, _ := os.ReadFile(filepath.Join(dir, "args.txt")); string(args) != "test --json --fuzz-seed 0x2a\n" {
+		t.Errorf("unexpected forge arguments %q", args)
+	}
+}
+
+func TestForgeArgs(t *testing.T) {
+	for _, test := range []struct {
+		tests    []string
+		testArgs string
+		expected []string
+	}{
+		{nil, "", nil},
+		{nil, "-vvv --replay=fuzz-failures.json", []string{"-vvv"}},
+		{[]string{"A.test_X"}, "test_Y --replay fuzz-failures.json -vvv", []string{"-vvv", "--match-test", "^(test_X|test_Y)$"}},
+		{[]string{"A.test_X"}, "", []string{"--match-test", "^(test_X)$", "--match-contract", "^(A)$"}},
+	} {
+		if args := ForgeArgs(test.tests, test.testArgs); !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("%q %q: expected %q, got %q", test.tests, test.testArgs, test.expected, args)
+		}
+	}
+}